@@ -0,0 +1,133 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+)
+
+// incidentStatuses 指标告警事件状态, 每个事件都会为这些状态各输出一个序列 (当前状态为 1, 其余为 0),
+// 无法识别的状态额外输出 status="unknown"
+var incidentStatuses = []string{"open", "warning", "critical", "resolved"}
+
+// thresholdTypeName 返回告警规则阈值类型的名称
+func thresholdTypeName(thresholdType int) string {
+	if thresholdType == 1 {
+		return "below"
+	}
+	return "above"
+}
+
+// fetchAlerts 获取组织的指标告警规则以及 IncidentsWindow 内的告警事件
+func (c *SentryCollector) fetchAlerts(ctx context.Context, data *sentryData) {
+	rules, err := c.sentryAPI.AlertRules(ctx, data.Org.Slug)
+	if err != nil {
+		log.Printf("Failed to fetch alert rules for organization %s: %v\n", data.Org.Slug, err)
+	}
+	data.AlertRules = rules
+
+	incidents, err := c.sentryAPI.Incidents(ctx, data.Org.Slug, c.opts.IncidentsWindow)
+	if err != nil {
+		log.Printf("Failed to fetch incidents for organization %s: %v\n", data.Org.Slug, err)
+	}
+	data.Incidents = incidents
+}
+
+// collectAlerts 收集指标告警规则的阈值配置以及事件的状态和开始时间
+func (c *SentryCollector) collectAlerts(ch chan<- prometheus.Metric, data *sentryData) {
+	projects := make(map[string]bool)
+	for _, project := range data.Projects {
+		projects[project.Slug] = true
+	}
+
+	ruleLabels := []string{"organization", "project_slug", "rule_id", "rule_name"}
+	ruleInfo := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_metric_alert_rule_info",
+			Help: "Metric alert rules per project, value is always 1",
+		},
+		[]string{"organization", "project_slug", "rule_id", "rule_name", "dataset", "aggregate", "query", "environment", "threshold_type"},
+	)
+	ruleTimeWindow := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_metric_alert_rule_time_window_seconds",
+			Help: "Time window a metric alert rule aggregates over",
+		},
+		ruleLabels,
+	)
+	ruleThreshold := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_metric_alert_rule_threshold",
+			Help: "Alert threshold of a metric alert rule trigger (critical/warning)",
+		},
+		[]string{"organization", "project_slug", "rule_id", "rule_name", "trigger"},
+	)
+	ruleResolveThreshold := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_metric_alert_rule_resolve_threshold",
+			Help: "Resolve threshold of a metric alert rule",
+		},
+		ruleLabels,
+	)
+	incidentLabels := []string{"organization", "project_slug", "rule_id", "rule_name", "incident_id"}
+	incidentStatus := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_metric_alert_incident_status",
+			Help: "Status of a metric alert incident, 1 for the current status and 0 for the others",
+		},
+		append(incidentLabels, "status"),
+	)
+	incidentStart := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_metric_alert_incident_start_timestamp_seconds",
+			Help: "Unix timestamp a metric alert incident started",
+		},
+		incidentLabels,
+	)
+
+	for _, rule := range data.AlertRules {
+		for _, projectSlug := range rule.Projects {
+			if !projects[projectSlug] {
+				continue
+			}
+			ruleInfo.WithLabelValues(data.Org.Slug, projectSlug, rule.ID, rule.Name, rule.Dataset, rule.Aggregate, rule.Query,
+				rule.Environment, thresholdTypeName(rule.ThresholdType)).Set(1)
+			ruleTimeWindow.WithLabelValues(data.Org.Slug, projectSlug, rule.ID, rule.Name).Set(rule.TimeWindow * 60)
+			if rule.ResolveThreshold != nil {
+				ruleResolveThreshold.WithLabelValues(data.Org.Slug, projectSlug, rule.ID, rule.Name).Set(*rule.ResolveThreshold)
+			}
+			for _, trigger := range rule.Triggers {
+				if trigger.AlertThreshold == nil {
+					continue
+				}
+				ruleThreshold.WithLabelValues(data.Org.Slug, projectSlug, rule.ID, rule.Name, trigger.Label).Set(*trigger.AlertThreshold)
+			}
+		}
+	}
+
+	for _, incident := range data.Incidents {
+		status := incident.StatusName()
+		for _, projectSlug := range incident.Projects {
+			if !projects[projectSlug] {
+				continue
+			}
+			for _, s := range incidentStatuses {
+				incidentStatus.WithLabelValues(data.Org.Slug, projectSlug, incident.AlertRule.ID, incident.AlertRule.Name,
+					incident.Identifier, s).Set(boolToFloat(s == status))
+			}
+			if status == "unknown" {
+				incidentStatus.WithLabelValues(data.Org.Slug, projectSlug, incident.AlertRule.ID, incident.AlertRule.Name,
+					incident.Identifier, status).Set(1)
+			}
+			incidentStart.WithLabelValues(data.Org.Slug, projectSlug, incident.AlertRule.ID, incident.AlertRule.Name,
+				incident.Identifier).Set(float64(incident.DateStarted.Unix()))
+		}
+	}
+
+	ruleInfo.Collect(ch)
+	ruleTimeWindow.Collect(ch)
+	ruleThreshold.Collect(ch)
+	ruleResolveThreshold.Collect(ch)
+	incidentStatus.Collect(ch)
+	incidentStart.Collect(ch)
+}
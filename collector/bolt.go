@@ -0,0 +1,83 @@
+package collector
+
+import (
+	"fmt"
+	bolt "go.etcd.io/bbolt"
+	"time"
+)
+
+// snapshotsBucket bbolt 中保存 Snapshot 的 bucket, key 为实例名称
+var snapshotsBucket = []byte("snapshots")
+
+// BoltDB 嵌入式 bbolt 数据库, 多个 Sentry 实例共用一个数据库文件
+type BoltDB struct {
+	db *bolt.DB
+}
+
+// OpenBoltDB 打开 (不存在时创建) path 处的 bbolt 数据库
+// bbolt 同一时间只允许一个进程打开数据库文件, 文件被占用时 5 秒后返回错误
+func OpenBoltDB(path string) (*BoltDB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bolt database %s: %v", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(snapshotsBucket)
+		return err
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create bucket in bolt database %s: %v", path, err)
+	}
+	return &BoltDB{db: db}, nil
+}
+
+// Store 返回将 Snapshot 保存在 key 为 name 的 SnapshotStore
+func (b *BoltDB) Store(name string) SnapshotStore {
+	return &boltStore{db: b.db, key: []byte(name)}
+}
+
+// Close 关闭数据库, 之后通过 Store 得到的 SnapshotStore 都不可再使用
+func (b *BoltDB) Close() error {
+	return b.db.Close()
+}
+
+// boltStore 保存在 BoltDB 中的 SnapshotStore
+type boltStore struct {
+	db  *bolt.DB
+	key []byte
+}
+
+func (s *boltStore) Load() (*Snapshot, error) {
+	var data []byte
+	err := s.db.View(func(tx *bolt.Tx) error {
+		// Get 返回的数据只在事务内有效, 需要复制
+		data = append([]byte(nil), tx.Bucket(snapshotsBucket).Get(s.key)...)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read snapshot %s: %v", s.key, err)
+	}
+	if len(data) == 0 {
+		return nil, nil
+	}
+	snap, err := decodeSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("snapshot %s: %v", s.key, err)
+	}
+	return snap, nil
+}
+
+func (s *boltStore) Save(snap *Snapshot) error {
+	data, err := encodeSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	err = s.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(snapshotsBucket).Put(s.key, data)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write snapshot %s: %v", s.key, err)
+	}
+	return nil
+}
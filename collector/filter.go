@@ -0,0 +1,150 @@
+package collector
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sentry-exporter/sentry"
+	"strings"
+)
+
+// ProjectActiveStatus 正常状态项目的 status 取值, 其他取值如 pending_deletion、disabled
+const ProjectActiveStatus = "active"
+
+// projectPattern 项目匹配规则: 以 "re:" 开头的为正则表达式, 否则为 glob (path.Match 语法), 不含通配符时即为精确匹配
+type projectPattern struct {
+	glob string
+	re   *regexp.Regexp
+}
+
+// match 判断项目 slug 是否匹配规则
+func (p projectPattern) match(slug string) bool {
+	if p.re != nil {
+		return p.re.MatchString(slug)
+	}
+	ok, _ := path.Match(p.glob, slug)
+	return ok
+}
+
+// ProjectFilter 在发现组织下的全部项目后, 按 slug、平台、团队和状态筛选需要采集的项目
+type ProjectFilter struct {
+	include   []projectPattern
+	exclude   []projectPattern
+	platforms []projectPattern
+	teams     []projectPattern
+	// includeInactive 为 true 时保留 status 不是 active 的项目
+	includeInactive bool
+}
+
+// NewProjectFilter 创建项目过滤器, include/exclude/platforms/teams 均为匹配规则列表, 为空表示不限制
+// 项目需匹配任一 include 规则且不匹配任何 exclude 规则, 平台和团队同理
+func NewProjectFilter(include, exclude, platforms, teams []string, includeInactive bool) (*ProjectFilter, error) {
+	f := &ProjectFilter{includeInactive: includeInactive}
+	var err error
+	if f.include, err = compilePatterns(include); err != nil {
+		return nil, fmt.Errorf("invalid project include pattern: %v", err)
+	}
+	if f.exclude, err = compilePatterns(exclude); err != nil {
+		return nil, fmt.Errorf("invalid project exclude pattern: %v", err)
+	}
+	if f.platforms, err = compilePatterns(platforms); err != nil {
+		return nil, fmt.Errorf("invalid project platform pattern: %v", err)
+	}
+	if f.teams, err = compilePatterns(teams); err != nil {
+		return nil, fmt.Errorf("invalid project team pattern: %v", err)
+	}
+	return f, nil
+}
+
+// compilePatterns 编译匹配规则, glob 语法错误和正则表达式错误都会返回 error
+func compilePatterns(patterns []string) ([]projectPattern, error) {
+	var compiled []projectPattern
+	for _, pattern := range patterns {
+		if strings.HasPrefix(pattern, "re:") {
+			expr := strings.TrimPrefix(pattern, "re:")
+			if unbalancedBraces(expr) {
+				return nil, fmt.Errorf("%s: unbalanced braces, write a comma inside a pattern of a comma separated list as \\,", pattern)
+			}
+			re, err := regexp.Compile(expr)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", pattern, err)
+			}
+			compiled = append(compiled, projectPattern{re: re})
+			continue
+		}
+		if _, err := path.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("%s: %v", pattern, err)
+		}
+		compiled = append(compiled, projectPattern{glob: pattern})
+	}
+	return compiled, nil
+}
+
+// unbalancedBraces 判断正则表达式中 (字符类之外) 未转义的 { 和 } 是否不成对
+// Go 正则表达式把不成对的 { 当作普通字符, 不成对通常说明 {m,n} 被逗号分隔的列表拆开了
+func unbalancedBraces(expr string) bool {
+	depth, class := 0, false
+	for i := 0; i < len(expr); i++ {
+		switch c := expr[i]; {
+		case c == '\\':
+			i++
+		case class:
+			class = c != ']'
+		case c == '[':
+			class = true
+		case c == '{':
+			depth++
+		case c == '}':
+			if depth--; depth < 0 {
+				return true
+			}
+		}
+	}
+	return depth != 0
+}
+
+// matchAny 判断任一取值是否匹配任一规则
+func matchAny(patterns []projectPattern, values ...string) bool {
+	for _, p := range patterns {
+		for _, value := range values {
+			if p.match(value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Match 判断项目是否需要采集, teams 为拥有该项目的团队 slug
+func (f *ProjectFilter) Match(project sentry.Project, teams []string) bool {
+	if f == nil {
+		return true
+	}
+	if !f.includeInactive && project.Status != "" && project.Status != ProjectActiveStatus {
+		return false
+	}
+	if len(f.include) > 0 && !matchAny(f.include, project.Slug) {
+		return false
+	}
+	if matchAny(f.exclude, project.Slug) {
+		return false
+	}
+	if len(f.platforms) > 0 && !matchAny(f.platforms, project.Platform) {
+		return false
+	}
+	if len(f.teams) > 0 && !matchAny(f.teams, teams...) {
+		return false
+	}
+	return true
+}
+
+// Filter 返回需要采集的项目
+func (f *ProjectFilter) Filter(projects []sentry.Project, projectsTeams map[string][]string) []sentry.Project {
+	var filtered []sentry.Project
+	for _, project := range projects {
+		if f.Match(project, projectsTeams[project.Slug]) {
+			filtered = append(filtered, project)
+		}
+	}
+	return filtered
+}
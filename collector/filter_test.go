@@ -0,0 +1,161 @@
+package collector
+
+import (
+	"sentry-exporter/sentry"
+	"testing"
+)
+
+func TestProjectFilterMatch(t *testing.T) {
+	type filter struct {
+		include, exclude, platforms, teams []string
+		includeInactive                    bool
+	}
+	tests := []struct {
+		name    string
+		filter  filter
+		project sentry.Project
+		teams   []string
+		want    bool
+	}{
+		{
+			name:    "no rules",
+			project: sentry.Project{Slug: "web", Status: "active"},
+			want:    true,
+		},
+		{
+			name:    "status not returned",
+			project: sentry.Project{Slug: "web"},
+			want:    true,
+		},
+		{
+			name:    "inactive project",
+			project: sentry.Project{Slug: "web", Status: "pending_deletion"},
+			want:    false,
+		},
+		{
+			name:    "inactive project included",
+			filter:  filter{includeInactive: true},
+			project: sentry.Project{Slug: "web", Status: "disabled"},
+			want:    true,
+		},
+		{
+			name:    "exact include",
+			filter:  filter{include: []string{"api", "web"}},
+			project: sentry.Project{Slug: "web"},
+			want:    true,
+		},
+		{
+			name:    "not included",
+			filter:  filter{include: []string{"api"}},
+			project: sentry.Project{Slug: "web"},
+			want:    false,
+		},
+		{
+			name:    "glob include",
+			filter:  filter{include: []string{"api-*"}},
+			project: sentry.Project{Slug: "api-gateway"},
+			want:    true,
+		},
+		{
+			name:    "regexp include",
+			filter:  filter{include: []string{"re:^(api|web)-(eu|us)$"}},
+			project: sentry.Project{Slug: "web-eu"},
+			want:    true,
+		},
+		{
+			name:    "regexp is not anchored",
+			filter:  filter{include: []string{"re:gateway"}},
+			project: sentry.Project{Slug: "api-gateway-v2"},
+			want:    true,
+		},
+		{
+			name:    "exclude wins over include",
+			filter:  filter{include: []string{"api-*"}, exclude: []string{"*-staging"}},
+			project: sentry.Project{Slug: "api-staging"},
+			want:    false,
+		},
+		{
+			name:    "platform",
+			filter:  filter{platforms: []string{"javascript*"}},
+			project: sentry.Project{Slug: "web", Platform: "javascript-react"},
+			want:    true,
+		},
+		{
+			name:    "other platform",
+			filter:  filter{platforms: []string{"python"}},
+			project: sentry.Project{Slug: "web", Platform: "javascript-react"},
+			want:    false,
+		},
+		{
+			name:    "any team matches",
+			filter:  filter{teams: []string{"backend"}},
+			project: sentry.Project{Slug: "api"},
+			teams:   []string{"frontend", "backend"},
+			want:    true,
+		},
+		{
+			name:    "no team",
+			filter:  filter{teams: []string{"backend"}},
+			project: sentry.Project{Slug: "api"},
+			want:    false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			f, err := NewProjectFilter(tt.filter.include, tt.filter.exclude, tt.filter.platforms, tt.filter.teams, tt.filter.includeInactive)
+			if err != nil {
+				t.Fatalf("NewProjectFilter() = %v", err)
+			}
+			if got := f.Match(tt.project, tt.teams); got != tt.want {
+				t.Errorf("Match(%+v, %v) = %v, want %v", tt.project, tt.teams, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNewProjectFilterInvalidPatterns(t *testing.T) {
+	tests := []struct {
+		name                               string
+		include, exclude, platforms, teams []string
+	}{
+		{name: "invalid glob", include: []string{"api-["}},
+		{name: "invalid regexp", exclude: []string{"re:api-("}},
+		{name: "invalid platform", platforms: []string{"re:*"}},
+		{name: "invalid team", teams: []string{"[a-"}},
+		// re:^api-\d{1,3}$ 在逗号分隔的列表中未转义逗号时被拆开
+		{name: "split quantifier", include: []string{`re:^api-\d{1`, "3}$"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := NewProjectFilter(tt.include, tt.exclude, tt.platforms, tt.teams, false); err == nil {
+				t.Errorf("NewProjectFilter() = nil, want error")
+			}
+		})
+	}
+}
+
+func TestUnbalancedBraces(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`^api-\d{1,3}$`, false},
+		{`^web-(prod|staging)$`, false},
+		{`^api-\d{1`, true},
+		{`3}$`, true},
+		{`^api-\{1$`, false},
+		{`^api-[{]$`, false},
+	}
+	for _, tt := range tests {
+		if got := unbalancedBraces(tt.expr); got != tt.want {
+			t.Errorf("unbalancedBraces(%q) = %v, want %v", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestNilProjectFilter(t *testing.T) {
+	var f *ProjectFilter
+	if !f.Match(sentry.Project{Slug: "web", Status: "disabled"}, nil) {
+		t.Errorf("nil filter rejected a project")
+	}
+}
@@ -0,0 +1,410 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sentry-exporter/sentry"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const (
+	// DefaultIssuesFullSyncInterval 未配置 IssuesFullSyncInterval 时全量同步问题的间隔
+	DefaultIssuesFullSyncInterval = 30 * time.Minute
+	// issueSyncOverlap 增量同步的 lastSeen 条件比上一次同步开始时间提前的时长, 避免遗漏写入延迟的事件
+	issueSyncOverlap = 5 * time.Minute
+)
+
+// indexedIssue 问题索引中的一个问题
+type indexedIssue struct {
+	Issue sentry.Issue `json:"issue"`
+	// Release 问题在所属环境的当前版本, HasRelease 为 false 表示尚未获取成功, 下一次同步时重试
+	Release    string `json:"release"`
+	HasRelease bool   `json:"has_release"`
+}
+
+// issueIndex 单个组织首次出现在最长时间窗口内的问题, 所有问题指标都由它计算
+// 每次刷新基于上一次的索引生成新的索引, 已发布的索引不再修改
+type issueIndex struct {
+	// Projects project slug -> environment -> issue ID -> 问题
+	Projects map[string]map[string]map[string]indexedIssue `json:"projects"`
+	// MaxAge 索引覆盖的时间窗口 (启用的最长窗口), 变化时需要全量同步
+	MaxAge string `json:"max_age"`
+	// BuiltAt 索引生成的时间, 各时间窗口以此为准
+	BuiltAt time.Time `json:"built_at"`
+	// SyncedAt 增量同步的水位线, 下一次增量同步只获取此后 (减去 issueSyncOverlap) lastSeen 的问题
+	SyncedAt time.Time `json:"synced_at"`
+	// FullSyncAt 上一次成功全量同步的时间, 为零时下一次同步为全量同步
+	FullSyncAt time.Time `json:"full_sync_at"`
+}
+
+// issueAges 返回启用的问题统计时间窗口, 从短到长排列
+func (c *SentryCollector) issueAges() []string {
+	var ages []string
+	if c.opts.Issues1H {
+		ages = append(ages, "1h")
+	}
+	if c.opts.Issues24H {
+		ages = append(ages, "24h")
+	}
+	if c.opts.Issues14D {
+		ages = append(ages, "14d")
+	}
+	return ages
+}
+
+// previousIssues 返回当前 snapshot 中组织的问题索引, 没有时返回 nil
+func (c *SentryCollector) previousIssues(orgSlug string) *issueIndex {
+	if data := c.previousOrg(orgSlug); data != nil {
+		return data.Issues
+	}
+	return nil
+}
+
+// needFullIssueSync 判断本次是否需要全量同步: 没有上一次的索引、时间窗口变化、出现了新的项目/环境,
+// 或距离上一次全量同步超过 IssuesFullSyncInterval (用于发现已解决等不更新 lastSeen 的变化)
+func (c *SentryCollector) needFullIssueSync(data *sentryData, prev *issueIndex, maxAge string, now time.Time) bool {
+	if prev == nil || prev.MaxAge != maxAge || prev.FullSyncAt.IsZero() {
+		return true
+	}
+	if c.opts.IssuesFullSyncInterval <= 0 || now.Sub(prev.FullSyncAt) >= c.opts.IssuesFullSyncInterval {
+		return true
+	}
+	for _, project := range data.Projects {
+		for _, env := range data.ProjectsEnvs[project.Slug] {
+			if _, ok := prev.Projects[project.Slug][env]; !ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// syncIssues 同步组织的问题索引并保存到 data.Issues
+// 增量同步只获取上一次同步后 lastSeen 更新的问题, 合并到上一次的索引中, 再删除首次出现时间超出窗口的问题;
+// 部分请求失败时沿用上一次的数据, 并且不推进水位线, 下一次同步重新覆盖这段时间
+func (c *SentryCollector) syncIssues(ctx context.Context, data *sentryData, prev *issueIndex) {
+	ages := c.issueAges()
+	if len(ages) == 0 {
+		return
+	}
+	maxAge := ages[len(ages)-1]
+	window, err := parseStatsPeriod(maxAge)
+	if err != nil {
+		log.Printf("Failed to sync issues: %v\n", err)
+		return
+	}
+
+	now := time.Now()
+	full := c.needFullIssueSync(data, prev, maxAge, now)
+	var since time.Time
+	if !full {
+		since = prev.SyncedAt.Add(-issueSyncOverlap)
+		log.Printf("metadata: syncing issues updated since %s for organization %s\n", since.Format(time.RFC3339), data.Org.Slug)
+	} else {
+		log.Printf("metadata: full issues sync for organization %s\n", data.Org.Slug)
+	}
+
+	var fetched map[string]map[string][]sentry.Issue
+	if c.opts.OrgIssues {
+		fetched = c.fetchOrgIssues(ctx, data, maxAge, since)
+	} else {
+		fetched = c.fetchProjectIssues(ctx, data, maxAge, since)
+	}
+
+	index := &issueIndex{
+		Projects:   make(map[string]map[string]map[string]indexedIssue),
+		MaxAge:     maxAge,
+		BuiltAt:    now,
+		SyncedAt:   now,
+		FullSyncAt: now,
+	}
+	if !full {
+		index.FullSyncAt = prev.FullSyncAt
+	}
+	failed := false
+	cutoff := now.Add(-window)
+	for _, project := range data.Projects {
+		for _, env := range data.ProjectsEnvs[project.Slug] {
+			var previous map[string]indexedIssue
+			if prev != nil {
+				previous = prev.Projects[project.Slug][env]
+			}
+			issues, ok := fetched[project.Slug][env]
+			if !ok {
+				failed = true
+				if previous == nil {
+					continue
+				}
+			}
+
+			entries := make(map[string]indexedIssue)
+			// 全量同步成功时丢弃上一次的数据, 其余情况在上一次的基础上合并
+			if !full || !ok {
+				for id, entry := range previous {
+					entries[id] = entry
+				}
+			}
+			for _, issue := range issues {
+				entry := indexedIssue{Issue: issue}
+				// lastSeen 没有变化的问题 (全量同步中的大部分问题) 沿用已获取的版本, 只为新问题和有新事件的问题重新获取
+				if old, ok := previous[issue.ID]; ok && old.HasRelease && old.Issue.LastSeen.Equal(issue.LastSeen) {
+					entry.Release, entry.HasRelease = old.Release, true
+				}
+				entries[issue.ID] = entry
+			}
+			for id, entry := range entries {
+				if entry.Issue.FirstSeen.Before(cutoff) {
+					delete(entries, id)
+				}
+			}
+			if _, ok := index.Projects[project.Slug]; !ok {
+				index.Projects[project.Slug] = make(map[string]map[string]indexedIssue)
+			}
+			index.Projects[project.Slug][env] = entries
+		}
+	}
+	if failed {
+		if full {
+			index.FullSyncAt = time.Time{}
+		} else {
+			index.SyncedAt = prev.SyncedAt
+		}
+	}
+
+	c.fetchIssuesRelease(ctx, index)
+	data.Issues = index
+}
+
+// fetchProjectIssues 按 项目 x 环境 并发获取首次出现在 age 之内的问题, since 非零时只获取此后 lastSeen 的问题
+// 返回 project slug -> environment -> issues, 获取失败的项目/环境不在结果中
+func (c *SentryCollector) fetchProjectIssues(ctx context.Context, data *sentryData, age string, since time.Time) map[string]map[string][]sentry.Issue {
+	type job struct {
+		project sentry.Project
+		env     string
+		issues  []sentry.Issue
+		ok      bool
+	}
+	fetched := make(map[string]map[string][]sentry.Issue)
+	var jobs []*job
+	for _, project := range data.Projects {
+		envs, ok := data.ProjectsEnvs[project.Slug]
+		if !ok {
+			continue
+		}
+		fetched[project.Slug] = make(map[string][]sentry.Issue)
+		for _, env := range envs {
+			jobs = append(jobs, &job{project: project, env: env})
+		}
+	}
+
+	c.parallel(len(jobs), func(i int) {
+		j := jobs[i]
+		log.Printf("metadata: getting issues from API - project: %s env: %s age: %s\n", j.project.Slug, j.env, age)
+		issues, err := c.sentryAPI.Issues(ctx, data.Org.Slug, j.project, j.env, age, since)
+		if err != nil {
+			log.Printf("Failed to fetch issues for project %s, env %s, age %s: %v\n", j.project.Slug, j.env, age, err)
+			return
+		}
+		j.issues, j.ok = issues, true
+	})
+
+	for _, j := range jobs {
+		if j.ok {
+			fetched[j.project.Slug][j.env] = j.issues
+		}
+	}
+	return fetched
+}
+
+// fetchOrgIssues 按环境调用组织级 issues 接口, 再按问题所属项目拆分到各项目/环境, 参数和返回值与 fetchProjectIssues 相同
+func (c *SentryCollector) fetchOrgIssues(ctx context.Context, data *sentryData, age string, since time.Time) map[string]map[string][]sentry.Issue {
+	// 过滤掉了部分项目时才按项目 ID 查询, 否则使用 project=-1 查询全部项目
+	var projectIDs []string
+	if data.ProjectsFiltered {
+		for _, project := range data.Projects {
+			projectIDs = append(projectIDs, project.ID)
+		}
+	}
+
+	// 汇总所有项目的环境, 并记录每个环境下有哪些项目
+	envProjects := make(map[string]map[string]bool)
+	var envs []string
+	for _, project := range data.Projects {
+		for _, env := range data.ProjectsEnvs[project.Slug] {
+			if _, ok := envProjects[env]; !ok {
+				envProjects[env] = make(map[string]bool)
+				envs = append(envs, env)
+			}
+			envProjects[env][project.Slug] = true
+		}
+	}
+	sort.Strings(envs)
+
+	results := make([][]sentry.Issue, len(envs))
+	ok := make([]bool, len(envs))
+	c.parallel(len(envs), func(i int) {
+		log.Printf("metadata: getting organization issues from API - env: %s age: %s\n", envs[i], age)
+		issues, err := c.sentryAPI.OrgIssues(ctx, data.Org.Slug, projectIDs, envs[i], age, since)
+		if err != nil {
+			log.Printf("Failed to fetch organization issues for env %s, age %s: %v\n", envs[i], age, err)
+			return
+		}
+		results[i], ok[i] = issues, true
+	})
+
+	fetched := make(map[string]map[string][]sentry.Issue)
+	for i, env := range envs {
+		if !ok[i] {
+			continue
+		}
+		issues := results[i]
+
+		// 先为该环境下的每个项目初始化空列表, 使没有问题的项目同样产生指标
+		for projectSlug := range envProjects[env] {
+			if _, ok := fetched[projectSlug]; !ok {
+				fetched[projectSlug] = make(map[string][]sentry.Issue)
+			}
+			fetched[projectSlug][env] = []sentry.Issue{}
+		}
+		for _, issue := range issues {
+			projectSlug := issue.Project.Slug
+			if !envProjects[env][projectSlug] {
+				continue
+			}
+			fetched[projectSlug][env] = append(fetched[projectSlug][env], issue)
+		}
+	}
+	return fetched
+}
+
+// fetchIssuesRelease 并发获取索引中尚未获取当前版本的问题 (新出现、有新事件或上一次获取失败的问题) 在所属环境的当前版本
+func (c *SentryCollector) fetchIssuesRelease(ctx context.Context, index *issueIndex) {
+	type job struct {
+		entries map[string]indexedIssue
+		env     string
+		id      string
+		release string
+		ok      bool
+	}
+	var jobs []*job
+	for _, envs := range index.Projects {
+		for env, entries := range envs {
+			for id, entry := range entries {
+				if !entry.HasRelease {
+					jobs = append(jobs, &job{entries: entries, env: env, id: id})
+				}
+			}
+		}
+	}
+
+	c.parallel(len(jobs), func(i int) {
+		j := jobs[i]
+		release, err := c.sentryAPI.IssueRelease(ctx, j.id, j.env)
+		if err != nil {
+			log.Printf("Failed to fetch release for issue %s: %v\n", j.id, err)
+			return
+		}
+		j.release, j.ok = release, true
+	})
+
+	for _, j := range jobs {
+		if !j.ok {
+			continue
+		}
+		entry := j.entries[j.id]
+		entry.Release = j.release
+		entry.HasRelease = true
+		j.entries[j.id] = entry
+	}
+}
+
+// collectIssues 由问题索引收集每个项目/环境的问题事件数分布以及每个问题的事件数
+func (c *SentryCollector) collectIssues(ch chan<- prometheus.Metric, data *sentryData) {
+	index := data.Issues
+	if index == nil {
+		return
+	}
+
+	// 创建一个直方图指标，用于记录每个项目及环境的未解决问题数量分布
+	issuesHistogramMetrics := prometheus.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "sentry_open_issues_histogram",
+			Help:    "Histogram of open issues (aka is:unresolved) count per project and environment",
+			Buckets: []float64{1, 5, 10, 50, 100, 500}, // 自定义的桶边界，根据实际情况调整
+		},
+		[]string{"organization", "project_slug", "environment"},
+	)
+	issuesMetrics := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_open_issue_events",
+			Help: "Number of open issues (aka is:unresolved) per project",
+		},
+		[]string{
+			"organization",
+			"issue_id",
+			"logger",
+			"level",
+			"status",
+			"platform",
+			"project_slug",
+			"environment",
+			"release",
+			"isUnhandled",
+			"firstSeen",
+			"lastSeen",
+		},
+	)
+
+	for _, project := range data.Projects {
+		for _, env := range data.ProjectsEnvs[project.Slug] {
+			entries, ok := index.Projects[project.Slug][env]
+			if !ok {
+				log.Printf("No issues data for project: %s env: %s\n", project.Slug, env)
+				continue
+			}
+			for _, age := range c.issueAges() {
+				window, err := parseStatsPeriod(age)
+				if err != nil {
+					continue
+				}
+				cutoff := index.BuiltAt.Add(-window)
+				var events int64
+				for _, entry := range entries {
+					if !entry.Issue.FirstSeen.Before(cutoff) {
+						events += int64(entry.Issue.Count)
+					}
+				}
+				issuesHistogramMetrics.WithLabelValues(
+					data.Org.Slug,
+					project.Slug,
+					env,
+				).Observe(float64(events))
+			}
+
+			// 获取版本失败的问题同样输出, release 为空
+			for _, entry := range entries {
+				issue := entry.Issue
+				issuesMetrics.WithLabelValues(
+					data.Org.Slug,
+					issue.ID,
+					issue.Logger,
+					issue.Level,
+					issue.Status,
+					issue.Platform,
+					project.Slug,
+					env,
+					entry.Release,
+					strconv.FormatBool(issue.IsUnhandled),
+					issue.FirstSeen.Format(time.RFC3339Nano),
+					issue.LastSeen.Format(time.RFC3339Nano),
+				).Set(float64(issue.Count))
+			}
+		}
+	}
+
+	issuesHistogramMetrics.Collect(ch)
+	issuesMetrics.Collect(ch)
+}
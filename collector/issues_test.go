@@ -0,0 +1,214 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sentry-exporter/sentry"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIssuesAPI 模拟项目级 issues 接口和 current-release 接口
+type fakeIssuesAPI struct {
+	// issues environment -> 问题, 增量同步时只返回 lastSeen 晚于查询条件的问题
+	issues map[string][]sentry.Issue
+	// broken 返回无法解码的响应的环境
+	broken map[string]bool
+
+	mu sync.Mutex
+	// incremental 是否收到过带 lastSeen 条件的查询
+	incremental bool
+	// releases 获取了当前版本的问题 ID
+	releases []string
+}
+
+func (f *fakeIssuesAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/current-release/"):
+		f.releases = append(f.releases, strings.Split(r.URL.Path, "/")[4])
+		w.Write([]byte(`{"currentRelease": {"release": {"version": "2.0"}}}`))
+	case strings.HasSuffix(r.URL.Path, "/issues/"):
+		env := r.URL.Query().Get("environment")
+		if f.broken[env] {
+			w.Write([]byte(`[{"id": `))
+			return
+		}
+		var since time.Time
+		if i := strings.Index(r.URL.Query().Get("query"), "lastSeen:>"); i >= 0 {
+			f.incremental = true
+			since, _ = time.Parse("2006-01-02T15:04:05", r.URL.Query().Get("query")[i+len("lastSeen:>"):])
+		}
+		issues := []sentry.Issue{}
+		for _, issue := range f.issues[env] {
+			if issue.LastSeen.After(since) {
+				issues = append(issues, issue)
+			}
+		}
+		json.NewEncoder(w).Encode(issues)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func TestSyncIssues(t *testing.T) {
+	now := time.Now().UTC().Truncate(time.Second)
+	issue := func(id string, firstSeen, lastSeen time.Duration) sentry.Issue {
+		return sentry.Issue{ID: id, FirstSeen: now.Add(-firstSeen), LastSeen: now.Add(-lastSeen), Project: sentry.IssueProject{Slug: "web"}}
+	}
+	indexed := func(issue sentry.Issue) indexedIssue {
+		return indexedIssue{Issue: issue, Release: "1.0", HasRelease: true}
+	}
+	a := issue("a", 2*time.Hour, 30*time.Minute)
+	b := issue("b", 3*time.Hour, time.Hour)
+	c := issue("c", 4*time.Hour, 2*time.Hour)
+	// 上一次同步后有新事件的 a, 首次出现的 d, 首次出现时间超出 24h 窗口的 e
+	aUpdated := issue("a", 2*time.Hour, time.Minute)
+	d := issue("d", time.Minute, time.Minute)
+	e := issue("e", 25*time.Hour, 3*time.Hour)
+
+	// previous 上一次同步得到的索引, fullSync 为上一次全量同步距今的时间
+	previous := func(fullSync time.Duration, envs map[string]map[string]indexedIssue) *issueIndex {
+		return &issueIndex{
+			Projects:   map[string]map[string]map[string]indexedIssue{"web": envs},
+			MaxAge:     "24h",
+			BuiltAt:    now.Add(-2 * time.Minute),
+			SyncedAt:   now.Add(-2 * time.Minute),
+			FullSyncAt: now.Add(-fullSync),
+		}
+	}
+	tests := []struct {
+		name   string
+		prev   *issueIndex
+		issues map[string][]sentry.Issue
+		broken map[string]bool
+		// want environment -> issue ID -> 当前版本
+		want         map[string]map[string]string
+		wantReleases []string
+		wantFull     bool
+		// wantAdvanced 同步是否完整, 完整时推进水位线, 否则下一次同步重新覆盖这段时间
+		wantAdvanced bool
+	}{
+		{
+			name:         "first sync",
+			issues:       map[string][]sentry.Issue{"production": {a, b}, "staging": {c}},
+			want:         map[string]map[string]string{"production": {"a": "2.0", "b": "2.0"}, "staging": {"c": "2.0"}},
+			wantReleases: []string{"a", "b", "c"},
+			wantFull:     true,
+			wantAdvanced: true,
+		},
+		{
+			name: "incremental sync merges updated issues",
+			prev: previous(10*time.Minute, map[string]map[string]indexedIssue{
+				"production": {"a": indexed(a), "b": indexed(b), "e": indexed(e)},
+				"staging":    {"c": indexed(c)},
+			}),
+			issues:       map[string][]sentry.Issue{"production": {aUpdated, b, d}, "staging": {c}},
+			want:         map[string]map[string]string{"production": {"a": "2.0", "b": "1.0", "d": "2.0"}, "staging": {"c": "1.0"}},
+			wantReleases: []string{"a", "d"},
+			wantAdvanced: true,
+		},
+		{
+			name: "full sync drops issues no longer returned and keeps known releases",
+			prev: previous(time.Hour, map[string]map[string]indexedIssue{
+				"production": {"a": indexed(a), "b": indexed(b)},
+				"staging":    {"c": indexed(c)},
+			}),
+			issues:       map[string][]sentry.Issue{"production": {a}, "staging": {c, d}},
+			want:         map[string]map[string]string{"production": {"a": "1.0"}, "staging": {"c": "1.0", "d": "2.0"}},
+			wantReleases: []string{"d"},
+			wantFull:     true,
+			wantAdvanced: true,
+		},
+		{
+			name: "new environment forces a full sync",
+			prev: previous(10*time.Minute, map[string]map[string]indexedIssue{
+				"production": {"a": indexed(a), "b": indexed(b)},
+			}),
+			issues:       map[string][]sentry.Issue{"production": {a, b}, "staging": {c}},
+			want:         map[string]map[string]string{"production": {"a": "1.0", "b": "1.0"}, "staging": {"c": "2.0"}},
+			wantReleases: []string{"c"},
+			wantFull:     true,
+			wantAdvanced: true,
+		},
+		{
+			name: "failed environment keeps the previous issues",
+			prev: previous(10*time.Minute, map[string]map[string]indexedIssue{
+				"production": {"a": indexed(a)},
+				"staging":    {"c": indexed(c)},
+			}),
+			issues:       map[string][]sentry.Issue{"production": {a, d}, "staging": {c}},
+			broken:       map[string]bool{"staging": true},
+			want:         map[string]map[string]string{"production": {"a": "1.0", "d": "2.0"}, "staging": {"c": "1.0"}},
+			wantReleases: []string{"d"},
+		},
+		{
+			name:         "failed first sync",
+			issues:       map[string][]sentry.Issue{"production": {a}},
+			broken:       map[string]bool{"staging": true},
+			want:         map[string]map[string]string{"production": {"a": "2.0"}},
+			wantReleases: []string{"a"},
+			wantFull:     true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeIssuesAPI{issues: tt.issues, broken: tt.broken}
+			server := httptest.NewServer(api)
+			defer server.Close()
+
+			c := NewSentryCollector(sentry.NewSentryAPI(server.URL+"/api/0/", "token"), []string{"acme"}, nil, Options{
+				Issues24H:              true,
+				IssuesFullSyncInterval: 30 * time.Minute,
+			})
+			data := &sentryData{
+				Org:          &sentry.Organization{Slug: "acme"},
+				Projects:     []sentry.Project{{ID: "11", Slug: "web"}},
+				ProjectsEnvs: map[string][]string{"web": {"production", "staging"}},
+			}
+			c.syncIssues(context.Background(), data, tt.prev)
+
+			index := data.Issues
+			got := make(map[string]map[string]string)
+			for env, entries := range index.Projects["web"] {
+				got[env] = make(map[string]string)
+				for id, entry := range entries {
+					if !entry.HasRelease {
+						t.Errorf("issue %s in %s has no release", id, env)
+					}
+					got[env][id] = entry.Release
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %v, want %v", got, tt.want)
+			}
+			sort.Strings(api.releases)
+			if !reflect.DeepEqual(api.releases, tt.wantReleases) {
+				t.Errorf("fetched releases of %v, want %v", api.releases, tt.wantReleases)
+			}
+			if api.incremental == tt.wantFull {
+				t.Errorf("incremental query = %v, want %v", api.incremental, !tt.wantFull)
+			}
+
+			switch {
+			case tt.wantAdvanced && index.SyncedAt.Before(now):
+				t.Errorf("SyncedAt = %s, want at least %s", index.SyncedAt, now)
+			case tt.wantAdvanced && tt.wantFull && !index.FullSyncAt.Equal(index.SyncedAt):
+				t.Errorf("FullSyncAt = %s, want %s", index.FullSyncAt, index.SyncedAt)
+			case tt.wantAdvanced && !tt.wantFull && !index.FullSyncAt.Equal(tt.prev.FullSyncAt):
+				t.Errorf("FullSyncAt = %s, want %s", index.FullSyncAt, tt.prev.FullSyncAt)
+			case !tt.wantAdvanced && tt.wantFull && !index.FullSyncAt.IsZero():
+				t.Errorf("FullSyncAt = %s, want zero so that the next sync is a full sync", index.FullSyncAt)
+			case !tt.wantAdvanced && !tt.wantFull && !index.SyncedAt.Equal(tt.prev.SyncedAt):
+				t.Errorf("SyncedAt = %s, want %s", index.SyncedAt, tt.prev.SyncedAt)
+			}
+		})
+	}
+}
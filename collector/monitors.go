@@ -0,0 +1,159 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sentry-exporter/sentry"
+	"strings"
+)
+
+// checkInStatuses 签到状态, 每个监控/环境都会为这些状态各输出一个序列 (当前状态为 1, 其余为 0)
+var checkInStatuses = []string{"ok", "error", "missed", "timeout", "in_progress"}
+
+// normalizeCheckInStatus 统一监控环境状态和签到状态的取值, 例如 missed_checkin -> missed
+func normalizeCheckInStatus(status string) string {
+	switch status {
+	case "missed_checkin":
+		return "missed"
+	case "in-progress":
+		return "in_progress"
+	}
+	return strings.ToLower(status)
+}
+
+// fetchMonitors 获取已采集项目的 Cron 监控, 并发获取每个监控/环境的最近一次签到
+func (c *SentryCollector) fetchMonitors(ctx context.Context, data *sentryData) {
+	monitors, err := c.sentryAPI.Monitors(ctx, data.Org.Slug)
+	if err != nil {
+		log.Printf("Failed to fetch monitors for organization %s: %v\n", data.Org.Slug, err)
+		return
+	}
+	projects := make(map[string]bool)
+	for _, project := range data.Projects {
+		projects[project.Slug] = true
+	}
+
+	// 不同项目下的监控可以使用相同的 slug, 按监控 ID 区分
+	type job struct {
+		monitor  sentry.Monitor
+		env      string
+		checkIns []sentry.CheckIn
+	}
+	data.MonitorCheckIns = make(map[string]map[string]sentry.CheckIn)
+	var jobs []*job
+	for _, monitor := range monitors {
+		if !projects[monitor.Project.Slug] {
+			continue
+		}
+		data.Monitors = append(data.Monitors, monitor)
+		data.MonitorCheckIns[monitor.ID] = make(map[string]sentry.CheckIn)
+		for _, env := range monitor.Environments {
+			jobs = append(jobs, &job{monitor: monitor, env: env.Name})
+		}
+	}
+
+	c.parallel(len(jobs), func(i int) {
+		j := jobs[i]
+		checkIns, err := c.sentryAPI.MonitorCheckIns(ctx, data.Org.Slug, j.monitor.ID, j.env, 1)
+		if err != nil {
+			log.Printf("Failed to fetch check-ins for monitor %s/%s, env %s: %v\n", j.monitor.Project.Slug, j.monitor.Slug, j.env, err)
+			return
+		}
+		j.checkIns = checkIns
+	})
+
+	for _, j := range jobs {
+		if len(j.checkIns) > 0 {
+			data.MonitorCheckIns[j.monitor.ID][j.env] = j.checkIns[0]
+		}
+	}
+}
+
+// collectMonitors 收集 Cron 监控的最近签到状态、签到时间、下次预期签到时间、耗时以及静音/禁用状态
+func (c *SentryCollector) collectMonitors(ch chan<- prometheus.Metric, data *sentryData) {
+
+	labels := []string{"organization", "project_slug", "monitor_slug", "environment"}
+	lastStatus := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_monitor_last_checkin_status",
+			Help: "Status of the last check-in of a cron monitor, 1 for the current status and 0 for the others",
+		},
+		[]string{"organization", "project_slug", "monitor_slug", "environment", "status"},
+	)
+	lastCheckIn := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_monitor_last_checkin_timestamp_seconds",
+			Help: "Unix timestamp of the last check-in of a cron monitor",
+		},
+		labels,
+	)
+	nextCheckIn := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_monitor_next_checkin_timestamp_seconds",
+			Help: "Unix timestamp of the next expected check-in of a cron monitor",
+		},
+		labels,
+	)
+	duration := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_monitor_last_checkin_duration_seconds",
+			Help: "Duration of the last check-in of a cron monitor",
+		},
+		labels,
+	)
+	muted := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_monitor_muted",
+			Help: "Whether a cron monitor environment is muted (1) or not (0)",
+		},
+		labels,
+	)
+	disabled := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_monitor_disabled",
+			Help: "Whether a cron monitor is disabled (1) or not (0)",
+		},
+		[]string{"organization", "project_slug", "monitor_slug"},
+	)
+
+	for _, monitor := range data.Monitors {
+		projectSlug := monitor.Project.Slug
+		disabled.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug).Set(boolToFloat(monitor.Status == "disabled"))
+
+		for _, env := range monitor.Environments {
+			status := normalizeCheckInStatus(env.Status)
+			if checkIn, ok := data.MonitorCheckIns[monitor.ID][env.Name]; ok {
+				status = normalizeCheckInStatus(checkIn.Status)
+				if checkIn.Duration != nil {
+					duration.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug, env.Name).Set(*checkIn.Duration / 1000)
+				}
+			}
+
+			// active 表示尚未签到, 所有状态均为 0; 未知状态单独输出一个序列
+			known := false
+			for _, s := range checkInStatuses {
+				lastStatus.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug, env.Name, s).Set(boolToFloat(s == status))
+				known = known || s == status
+			}
+			if !known && status != "active" {
+				lastStatus.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug, env.Name, status).Set(1)
+			}
+
+			if env.LastCheckIn != nil {
+				lastCheckIn.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug, env.Name).Set(float64(env.LastCheckIn.Unix()))
+			}
+			if env.NextCheckIn != nil {
+				nextCheckIn.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug, env.Name).Set(float64(env.NextCheckIn.Unix()))
+			}
+			muted.WithLabelValues(data.Org.Slug, projectSlug, monitor.Slug, env.Name).Set(boolToFloat(monitor.IsMuted || env.IsMuted))
+		}
+	}
+
+	lastStatus.Collect(ch)
+	lastCheckIn.Collect(ch)
+	nextCheckIn.Collect(ch)
+	duration.Collect(ch)
+	muted.Collect(ch)
+	disabled.Collect(ch)
+}
@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"strconv"
+)
+
+// DefaultOutcomeCategories 默认统计的数据类别
+var DefaultOutcomeCategories = []string{"error", "transaction", "attachment", "replay", "profile", "monitor", "span"}
+
+// fetchOutcomes 通过 stats_v2 获取各项目按 类别/结果/原因 分组的事件数, 一个组织只需一次请求
+func (c *SentryCollector) fetchOutcomes(ctx context.Context, data *sentryData) {
+	var projectIDs []string
+	if data.ProjectsFiltered {
+		for _, project := range data.Projects {
+			projectIDs = append(projectIDs, project.ID)
+		}
+	}
+
+	categories := c.opts.OutcomeCategories
+	if len(categories) == 0 {
+		categories = DefaultOutcomeCategories
+	}
+	window := c.opts.OutcomesWindow
+	result, err := c.sentryAPI.OrgStats(ctx, data.Org.Slug, projectIDs, categories, window, statsInterval(window))
+	if err != nil {
+		log.Printf("Failed to fetch outcomes for organization %s: %v\n", data.Org.Slug, err)
+		return
+	}
+	data.Outcomes = result
+}
+
+// collectOutcomes 收集各项目按 类别/结果/原因 分组的事件数
+func (c *SentryCollector) collectOutcomes(ch chan<- prometheus.Metric, data *sentryData) {
+	if data.Outcomes == nil {
+		return
+	}
+	projectSlugs := make(map[string]string)
+	for _, project := range data.Projects {
+		projectSlugs[project.ID] = project.Slug
+	}
+
+	outcomesMetrics := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_outcomes_total",
+			Help: "Quantity of data per project, category, outcome and reason in the outcomes window (bytes for attachments)",
+		},
+		[]string{"organization", "project_slug", "category", "outcome", "reason"},
+	)
+	for _, group := range data.Outcomes.Groups {
+		projectSlug, ok := projectSlugs[strconv.FormatInt(group.By.Project, 10)]
+		if !ok {
+			continue
+		}
+		outcomesMetrics.WithLabelValues(
+			data.Org.Slug,
+			projectSlug,
+			group.By.Category,
+			group.By.Outcome,
+			group.By.Reason,
+		).Add(group.Totals["sum(quantity)"])
+	}
+
+	outcomesMetrics.Collect(ch)
+}
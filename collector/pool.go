@@ -0,0 +1,60 @@
+package collector
+
+import (
+	"runtime/debug"
+	"sync"
+)
+
+// DefaultConcurrency 默认每个组织同时进行的 Sentry API 请求数
+const DefaultConcurrency = 4
+
+// workerPanic parallel 中 fn 的 panic, 保留 worker 中的调用栈, 重新 panic 后调用栈只到 parallel
+type workerPanic struct {
+	value interface{}
+	stack []byte
+}
+
+// parallel 用最多 Options.Concurrency 个 goroutine 执行 fn(0) ... fn(n-1), 全部完成后返回
+// fn 只写入下标 i 对应的结果, 调用方在返回后按下标顺序合并, 合并结果与顺序执行时一致;
+// fn panic 时在全部完成后于调用方的 goroutine 中重新 panic, 由 Refresh 统一恢复
+func (c *SentryCollector) parallel(n int, fn func(i int)) {
+	workers := c.opts.Concurrency
+	if workers > n {
+		workers = n
+	}
+	if workers <= 1 {
+		for i := 0; i < n; i++ {
+			fn(i)
+		}
+		return
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	var once sync.Once
+	var panicked *workerPanic
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				func() {
+					defer func() {
+						if r := recover(); r != nil {
+							once.Do(func() { panicked = &workerPanic{value: r, stack: debug.Stack()} })
+						}
+					}()
+					fn(i)
+				}()
+			}
+		}()
+	}
+	for i := 0; i < n; i++ {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	if panicked != nil {
+		panic(*panicked)
+	}
+}
@@ -1,649 +1,693 @@
 package collector
 
 import (
+	"context"
 	"fmt"
 	"github.com/prometheus/client_golang/prometheus"
 	"log"
+	"runtime/debug"
 	"sentry-exporter/sentry"
-	"strconv"
+	"sort"
+	"sync/atomic"
 	"time"
 )
 
 const (
-	JSONCacheFile               = "./sentry-collector-exporter-cache.json"
-	DefaultCacheExpireTimestamp = 2 * time.Minute
+	// JSONCacheFile default 实例的 FileStore 默认路径
+	JSONCacheFile = "./sentry-collector-exporter-cache.json"
+	// DefaultRefreshInterval 未配置 RefreshInterval 时后台刷新 snapshot 的间隔
+	DefaultRefreshInterval = 2 * time.Minute
 )
 
+var (
+	snapshotAgeDesc = prometheus.NewDesc(
+		"sentry_exporter_snapshot_age_seconds",
+		"Seconds since the data of an organization served by the exporter was fetched from the Sentry API",
+		[]string{"organization"}, nil,
+	)
+	snapshotRefreshDurationDesc = prometheus.NewDesc(
+		"sentry_exporter_snapshot_refresh_duration_seconds",
+		"Duration of the last snapshot refresh attempt, successful or not",
+		nil, nil,
+	)
+)
+
+// InstanceCacheFile 返回指定 Sentry 实例的 FileStore 默认路径
+func InstanceCacheFile(instance string) string {
+	return fmt.Sprintf("./sentry-collector-exporter-cache-%s.json", instance)
+}
+
+// Snapshot 后台刷新得到的所有组织的数据, 发布后不再修改, 由 SnapshotStore 持久化
+// CreatedAt 为生成 snapshot 的刷新开始的时间, 刷新超时时部分组织沿用上一次的数据, 各组织的数据时间见 sentryData.RefreshedAt
+type Snapshot struct {
+	Orgs      []*sentryData `json:"orgs"`
+	CreatedAt int64         `json:"created_at"`
+	ExpireAt  int64         `json:"expire_at"`
+}
+
+// sentryData 从 Sentry API 构建的单个组织的本地数据结构
+type sentryData struct {
+	// RefreshedAt 组织数据从 Sentry API 获取的时间
+	RefreshedAt  int64                `json:"refreshed_at"`
+	Org          *sentry.Organization `json:"org"`
+	Projects     []sentry.Project     `json:"projects"`
+	ProjectsSlug []string             `json:"projects_slug"`
+	ProjectsEnvs map[string][]string  `json:"projects_envs"`
+	// ProjectsFiltered 为 true 表示过滤掉了部分项目, 组织级接口需要按项目 ID 查询
+	ProjectsFiltered bool `json:"projects_filtered"`
+	// ProjectsTeams project slug -> 拥有该项目的团队 slug
+	ProjectsTeams map[string][]string `json:"projects_teams"`
+	// Issues 问题索引, 每次刷新在上一次的基础上增量同步
+	Issues *issueIndex `json:"issues,omitempty"`
+	// ProjectsStats project slug -> 本月事件统计
+	ProjectsStats map[string]sentry.Stats `json:"projects_stats,omitempty"`
+	// ProjectsRateLimit project slug -> 每秒限流事件数
+	ProjectsRateLimit map[string]float64 `json:"projects_rate_limit,omitempty"`
+	// Sessions project slug -> environment -> 会话统计
+	Sessions map[string]map[string]*sessionStats `json:"sessions,omitempty"`
+	// Outcomes stats_v2 按 项目/类别/结果/原因 分组的结果
+	Outcomes *sentry.OutcomesResult `json:"outcomes,omitempty"`
+	// Transactions project slug -> Discover 返回的事务行
+	Transactions map[string][]sentry.DiscoverRow `json:"transactions,omitempty"`
+	// Releases project slug -> environment -> 最近的发布版本
+	Releases map[string]map[string][]sentry.Release `json:"releases,omitempty"`
+	// ReleaseDeploys version -> 部署记录
+	ReleaseDeploys map[string][]sentry.Deploy `json:"release_deploys,omitempty"`
+	// Monitors Cron 监控, MonitorCheckIns monitor ID -> environment -> 最近一次签到
+	Monitors        []sentry.Monitor                     `json:"monitors,omitempty"`
+	MonitorCheckIns map[string]map[string]sentry.CheckIn `json:"monitor_check_ins,omitempty"`
+	// AlertRules 指标告警规则, Incidents 为 IncidentsWindow 内的告警事件
+	AlertRules []sentry.AlertRule `json:"alert_rules,omitempty"`
+	Incidents  []sentry.Incident  `json:"incidents,omitempty"`
+	// IssueAlerts project slug -> 问题告警规则在各窗口内的触发情况
+	IssueAlerts map[string][]issueAlertStats `json:"issue_alerts,omitempty"`
+}
+
+// Options 控制 SentryCollector 采集哪些指标以及如何访问 Sentry API
+type Options struct {
+	IssueMetrics     bool
+	EventsMetrics    bool
+	RateLimitMetrics bool
+	Issues1H         bool
+	Issues24H        bool
+	Issues14D        bool
+	// OrgIssues 为 true 时按环境调用组织级 issues 接口, 在本地按项目拆分, 否则按 项目 x 环境 逐个调用项目级 issues 接口
+	OrgIssues bool
+	// IssuesFullSyncInterval 问题全量同步的间隔, 两次全量同步之间只增量获取 lastSeen 更新的问题, <= 0 表示每次都全量同步
+	IssuesFullSyncInterval time.Duration
+	// SessionMetrics 为 true 时采集会话 (crash-free) 指标, 统计窗口为 SessionsWindow (如 24h)
+	SessionMetrics bool
+	SessionsWindow string
+	// OutcomeMetrics 为 true 时通过 stats_v2 采集 OutcomesWindow 内各类别数据的接收/丢弃情况,
+	// OutcomeCategories 为空时使用 DefaultOutcomeCategories
+	OutcomeMetrics    bool
+	OutcomesWindow    string
+	OutcomeCategories []string
+	// TransactionMetrics 为 true 时通过 Discover 采集每个项目 TransactionsLimit 个事务的性能指标,
+	// TransactionFields 为空时使用 DefaultTransactionFields
+	TransactionMetrics bool
+	TransactionsWindow string
+	TransactionFields  []string
+	TransactionsLimit  int
+	// ReleaseMetrics 为 true 时采集每个项目/环境最近 ReleasesLimit 个发布版本的指标,
+	// ReleasesWindow 为 adoption/crash-free 的统计窗口
+	ReleaseMetrics bool
+	ReleasesWindow string
+	ReleasesLimit  int
+	// MonitorMetrics 为 true 时采集 Cron 监控的签到指标
+	MonitorMetrics bool
+	// AlertMetrics 为 true 时采集指标告警规则, 以及 IncidentsWindow 内的告警事件
+	AlertMetrics    bool
+	IncidentsWindow string
+	// IssueAlertMetrics 为 true 时采集问题告警规则在 IssueAlertWindows 各窗口内的触发情况,
+	// IssueAlertWindows 为空时使用 DefaultIssueAlertWindows
+	IssueAlertMetrics bool
+	IssueAlertWindows []string
+	// RefreshInterval 后台刷新 snapshot 的间隔 (从上一次刷新结束开始计算), <= 0 时使用 DefaultRefreshInterval
+	RefreshInterval time.Duration
+	// RefreshTimeout 为单次刷新访问 Sentry API 的总时限, <= 0 表示不限制; 超时时只有已完成的组织使用新数据,
+	// 其余组织沿用上一次的数据, 因此需要大于刷新的正常耗时
+	RefreshTimeout time.Duration
+	// MaxStaleness 组织的数据刷新持续失败时最多继续使用多久, 超过后不再输出该组织的 Sentry 指标, <= 0 表示不限制
+	MaxStaleness time.Duration
+	// Store 保存每次刷新得到的 Snapshot, 为 nil 时使用 MemoryStore; 多个 SentryCollector 不能共用同一个 Store
+	Store SnapshotStore
+	// Concurrency 刷新时每个组织同时进行的 Sentry API 请求数, <= 0 时使用 DefaultConcurrency, 1 表示逐个请求
+	Concurrency int
+}
+
 // SentryCollector 结构体
+// 访问 Sentry API 的工作全部由 Run 在后台完成, Collect 只读取最近一次刷新得到的 snapshot
 type SentryCollector struct {
-	sentryAPI          *sentry.SentryAPI
-	sentryOrgSlug      string
-	sentryProjectsSlug []string
-	issueMetrics       bool
-	eventsMetrics      bool
-	rateLimitMetrics   bool
-	get1hMetrics       bool
-	get24hMetrics      bool
-	get14dMetrics      bool
-
-	org          map[string]interface{}
-	projectsData map[string]interface{}
+	sentryAPI      *sentry.SentryAPI
+	sentryOrgsSlug []string
+	projectFilter  *ProjectFilter
+	opts           Options
+	// snapshot 最近一次成功刷新的数据, 尚未刷新时为 nil
+	snapshot atomic.Pointer[Snapshot]
+	// refreshDuration 最近一次刷新 (无论成功与否) 的耗时, 尚未刷新时为 0
+	refreshDuration atomic.Int64
 }
 
 // NewSentryCollector 函数用于创建 SentryCollector 实例
-func NewSentryCollector(api *sentry.SentryAPI, orgSlug string, projectSlugs []string, metricConfig []bool) *SentryCollector {
+// orgSlugs 为空或包含 "*" 时采集 token 可见的所有组织, projectFilter 为 nil 时采集全部项目
+func NewSentryCollector(api *sentry.SentryAPI, orgSlugs []string, projectFilter *ProjectFilter, opts Options) *SentryCollector {
+	if opts.Store == nil {
+		opts.Store = NewMemoryStore()
+	}
+	if opts.RefreshInterval <= 0 {
+		opts.RefreshInterval = DefaultRefreshInterval
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
 	return &SentryCollector{
-		sentryAPI:          api,
-		sentryOrgSlug:      orgSlug,
-		sentryProjectsSlug: projectSlugs,
-		issueMetrics:       metricConfig[0],
-		eventsMetrics:      metricConfig[1],
-		rateLimitMetrics:   metricConfig[2],
-		get1hMetrics:       metricConfig[3],
-		get24hMetrics:      metricConfig[4],
-		get14dMetrics:      metricConfig[5],
+		sentryAPI:      api,
+		sentryOrgsSlug: orgSlugs,
+		projectFilter:  projectFilter,
+		opts:           opts,
+	}
+}
+
+// LoadSnapshot 将 Store 中还有组织没有超过 MaxStaleness 的 Snapshot 作为初始 snapshot, 重启或重新加载配置后可以立即返回指标
+// 需要在写入同一个 Store 的其他 collectors 退出后、Run 之前调用, 否则会错过它们最后一次保存的数据
+func (c *SentryCollector) LoadSnapshot() {
+	stored, err := c.opts.Store.Load()
+	if err != nil {
+		log.Printf("store: failed to load snapshot: %v\n", err)
+	} else if stored != nil && c.hasFreshOrg(stored) {
+		log.Printf("store: loaded snapshot created at %s\n", time.Unix(stored.CreatedAt, 0).Format(time.RFC3339))
+		c.snapshot.Store(stored)
 	}
 }
 
-// FetchSentryData is a public method to fetch Sentry data
-func (c *SentryCollector) FetchSentryData() map[string]interface{} {
-	return c.buildSentryDataFromAPI()
+// Run 每隔 RefreshInterval 刷新一次 snapshot, 直到 ctx 取消
+// 已有未过期的 snapshot 时等到过期再刷新, 否则立即刷新, 刷新期间继续使用已过期的 snapshot
+func (c *SentryCollector) Run(ctx context.Context) {
+	var wait time.Duration
+	if snap := c.snapshot.Load(); snap != nil {
+		wait = time.Until(time.Unix(snap.ExpireAt, 0))
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+		if err := c.Refresh(ctx); err != nil {
+			log.Printf("collector: failed to refresh snapshot, keeping the previous one: %v\n", err)
+		}
+		timer.Reset(c.opts.RefreshInterval)
+	}
 }
 
-// buildSentryDataFromAPI 用于从 Sentry API 构建本地数据结构
-func (c *SentryCollector) buildSentryDataFromAPI() map[string]interface{} {
-	var data map[string]interface{}
+// Refresh 从 Sentry API 构建新的 snapshot 并替换当前的 snapshot, 同时保存到 Store
+// 失败 (包括构建过程中 panic) 时保留当前的 snapshot
+func (c *SentryCollector) Refresh(ctx context.Context) (err error) {
+	ctx, cancel := c.refreshContext(ctx)
+	defer cancel()
+	defer func() {
+		if r := recover(); r != nil {
+			stack := debug.Stack()
+			if p, ok := r.(workerPanic); ok {
+				r, stack = p.value, p.stack
+			}
+			log.Printf("collector: panic during refresh: %v\n%s", r, stack)
+			err = fmt.Errorf("panic during refresh: %v", r)
+		}
+	}()
 
-	// 获取组织信息
-	org, err := c.sentryAPI.GetOrg(c.sentryOrgSlug)
+	start := time.Now()
+	defer func() {
+		c.refreshDuration.Store(int64(time.Since(start)))
+	}()
+	snap, refreshed, err := c.buildSnapshot(ctx, start)
 	if err != nil {
-		log.Printf("Failed to fetch organization: %v\n", err)
-		return nil
+		return err
+	}
+	snap.CreatedAt = start.Unix()
+	snap.ExpireAt = start.Add(c.opts.RefreshInterval).Unix()
+	c.snapshot.Store(snap)
+	if err := ctx.Err(); err != nil {
+		log.Printf("collector: refresh interrupted after %s (%v), %d organizations refreshed, %d partly or fully kept from the previous snapshot\n",
+			time.Since(start).Round(time.Millisecond), err, refreshed, len(snap.Orgs)-refreshed)
+	} else {
+		log.Printf("collector: snapshot refreshed in %s\n", time.Since(start).Round(time.Millisecond))
 	}
-	log.Printf("metadata: sentry organization: %s\n", org.Slug)
 
-	// 初始化数据结构
-	data = map[string]interface{}{
-		"metadata": map[string]interface{}{
-			"org":           org,
-			"projects":      []interface{}{},
-			"projects_slug": []string{},
-			"projects_envs": map[string]interface{}{},
-		},
+	if err := c.opts.Store.Save(snap); err != nil {
+		log.Printf("store: failed to save snapshot: %v\n", err)
 	}
+	return nil
+}
 
-	//// TODO 打印 data 变量的值
-	//dataJSON, err := json.MarshalIndent(data, "", "  ")
-	//if err != nil {
-	//	fmt.Println("Failed to marshal data:", err)
-	//}
-	//fmt.Println(string(dataJSON))
-
-	// 如果指定了项目，则获取项目信息
-	if len(c.sentryProjectsSlug) > 0 {
-		log.Printf("metadata: projects specified: %d\n", len(c.sentryProjectsSlug))
-		for _, projectSlug := range c.sentryProjectsSlug {
-			log.Printf("metadata: getting %s project data from API\n", projectSlug)
-			// 获取项目信息
-			// dhgate dhgate-addressbook-service
-			project, err := c.sentryAPI.GetProject(org.Slug, projectSlug)
-			if err != nil {
-				log.Printf("Failed to fetch project %s: %v\n", projectSlug, err)
-				continue
-			}
-			//fmt.Println("project: ", project)
-
-			// 更新data中的projects和projects_slug字段
-			projects := data["metadata"].(map[string]interface{})["projects"].([]interface{})
-			data["metadata"].(map[string]interface{})["projects"] = append(projects, project)
-			projectsSlug := data["metadata"].(map[string]interface{})["projects_slug"].([]string)
-			data["metadata"].(map[string]interface{})["projects_slug"] = append(projectsSlug, projectSlug)
-
-			// 获取项目环境信息
-			envs, err := c.sentryAPI.Environments(org.Slug, *project)
-			if err != nil {
-				log.Printf("Failed to fetch environments for project %s: %v\n", projectSlug, err)
-				continue
-			}
-			// 更新data中的projects_envs字段
-			projectsEnvs := data["metadata"].(map[string]interface{})["projects_envs"].(map[string]interface{})
-			projectsEnvs[projectSlug] = envs
-
-			// 构建项目问题数据
-			if c.issueMetrics {
-				projectsIssueData := make(map[string]map[string]map[string][]interface{})
-				for _, env := range envs {
-					projectsIssueData[projectSlug] = make(map[string]map[string][]interface{})
-
-					if c.get1hMetrics {
-						log.Printf("metadata: getting issues from API - project: %s env: %s age: 1h\n", projectSlug, env)
-						issues1h, err := c.sentryAPI.Issues(org.Slug, *project, env, "1h")
-						if err != nil {
-							log.Printf("Failed to fetch issues for project %s, env %s, age 1h: %v\n", projectSlug, env, err)
-							continue
-						}
-						// projectsIssueData[projectSlug][env]["1h"] = issues1h["all"].([]interface{})
-						// 确保map的每一层都已初始化
-						if _, ok := projectsIssueData[projectSlug]; !ok {
-							projectsIssueData[projectSlug] = make(map[string]map[string][]interface{})
-						}
-						if _, ok := projectsIssueData[projectSlug][env]; !ok {
-							projectsIssueData[projectSlug][env] = make(map[string][]interface{})
-						}
-
-						// 确保allIssues是一个切片，然后赋值
-						allIssues, ok := issues1h["all"].([]interface{})
-						if !ok {
-							log.Printf("Unexpected type for 'all' key in issues data, expected []interface{} but got %T", issues1h["all"])
-							continue
-						}
-						projectsIssueData[projectSlug][env]["1h"] = allIssues
-					}
-
-					if c.get24hMetrics {
-						log.Printf("metadata: getting issues from API - project: %s env: %s age: 24h\n", projectSlug, env)
-						issues24h, err := c.sentryAPI.Issues(org.Slug, *project, env, "24h")
-						//fmt.Println(issues24h, err) // map[all:[] message:No issues found] <nil>
-
-						if err != nil {
-							log.Printf("Failed to fetch issues for project %s, env %s, age 24h: %v\n", projectSlug, env, err)
-							continue
-						}
-						// projectsIssueData[projectSlug][env]["24h"] = issues24h["all"].([]interface{})
-						// 确保map的每一层都已初始化
-						if _, ok := projectsIssueData[projectSlug]; !ok {
-							projectsIssueData[projectSlug] = make(map[string]map[string][]interface{})
-						}
-
-						if _, ok := projectsIssueData[projectSlug][env]; !ok {
-							projectsIssueData[projectSlug][env] = make(map[string][]interface{})
-						}
-
-						// 确保allIssues是一个切片，然后赋值
-						allIssues, ok := issues24h["all"].([]interface{})
-						if !ok {
-							log.Printf("Unexpected type for 'all' key in issues data, expected []interface{} but got %T", issues24h["all"])
-							continue
-						}
-						projectsIssueData[projectSlug][env]["24h"] = allIssues
-					}
-
-					if c.get14dMetrics {
-						log.Printf("metadata: getting issues from API - project: %s env: %s age: 14d\n", projectSlug, env)
-						issues14d, err := c.sentryAPI.Issues(org.Slug, *project, env, "14d")
-						if err != nil {
-							log.Printf("Failed to fetch issues for project %s, env %s, age 14d: %v\n", projectSlug, env, err)
-							continue
-						}
-						// projectsIssueData[projectSlug][env]["14d"] = issues14d["all"].([]interface{})
-						// 确保map的每一层都已初始化
-						if _, ok := projectsIssueData[projectSlug]; !ok {
-							projectsIssueData[projectSlug] = make(map[string]map[string][]interface{})
-						}
-						if _, ok := projectsIssueData[projectSlug][env]; !ok {
-							projectsIssueData[projectSlug][env] = make(map[string][]interface{})
-						}
-
-						// 确保allIssues是一个切片，然后赋值
-						allIssues, ok := issues14d["all"].([]interface{})
-						if !ok {
-							log.Printf("Unexpected type for 'all' key in issues data, expected []interface{} but got %T", issues14d["all"])
-							continue
-						}
-						projectsIssueData[projectSlug][env]["14d"] = allIssues
-					}
-				}
-				data["projects_data"] = projectsIssueData
-			}
+// tooStale 判断组织的数据是否超过了 MaxStaleness
+func (c *SentryCollector) tooStale(snap *Snapshot, data *sentryData) bool {
+	return c.opts.MaxStaleness > 0 && orgAge(snap, data) > c.opts.MaxStaleness
+}
+
+// hasFreshOrg 判断 snapshot 中是否还有没有超过 MaxStaleness 的组织
+func (c *SentryCollector) hasFreshOrg(snap *Snapshot) bool {
+	for _, data := range snap.Orgs {
+		if !c.tooStale(snap, data) {
+			return true
 		}
-		log.Printf("metadata: projects loaded from API: %d\n", len(data["metadata"].(map[string]interface{})["projects"].([]interface{})))
-	} else {
-		log.Printf("metadata: no projects specified, loading from API\n")
-		// 获取组织下的所有项目信息
-		projects, err := c.sentryAPI.Projects(c.sentryOrgSlug)
-		if err != nil {
-			log.Printf("Failed to fetch projects: %v\n", err)
-			return nil
+	}
+	return false
+}
+
+// orgAge 返回组织的数据距离从 Sentry API 获取时的时长, 旧版本保存的 snapshot 中没有组织的刷新时间, 按 snapshot 的创建时间计算
+func orgAge(snap *Snapshot, data *sentryData) time.Duration {
+	refreshedAt := data.RefreshedAt
+	if refreshedAt == 0 {
+		refreshedAt = snap.CreatedAt
+	}
+	return time.Since(time.Unix(refreshedAt, 0))
+}
+
+// refreshContext 返回单次刷新使用的 context, 受 ctx 和 RefreshTimeout 共同约束
+func (c *SentryCollector) refreshContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.opts.RefreshTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.opts.RefreshTimeout)
+}
+
+// discoverOrgs 返回需要采集的组织: 未指定或指定了 "*" 时通过 API 获取 token 可见的所有组织
+func (c *SentryCollector) discoverOrgs(ctx context.Context) ([]string, error) {
+	discover := len(c.sentryOrgsSlug) == 0
+	for _, orgSlug := range c.sentryOrgsSlug {
+		if orgSlug == "*" {
+			discover = true
 		}
-		// 更新data中的projects和projects_slug字段
-		data["metadata"].(map[string]interface{})["projects"] = projects
-		for _, project := range projects {
-			projectsSlug := data["metadata"].(map[string]interface{})["projects_slug"].([]string)
-			data["metadata"].(map[string]interface{})["projects_slug"] = append(projectsSlug, project.Slug)
-			// 获取项目环境信息
-			envs, err := c.sentryAPI.Environments(org.Slug, project)
-			if err != nil {
-				log.Printf("Failed to fetch environments for project %s: %v\n", project.Slug, err)
-				continue
-			}
-			// 更新data中的projects_envs字段
-			projectsEnvs := data["metadata"].(map[string]interface{})["projects_envs"].(map[string]interface{})
-			projectsEnvs[project.Slug] = envs
-
-			// 构建项目问题数据
-			if c.issueMetrics {
-				projectsIssueData := make(map[string]map[string]map[string][]interface{})
-				for _, env := range envs {
-					projectsIssueData[project.Slug] = make(map[string]map[string][]interface{})
-
-					if c.get1hMetrics {
-						log.Printf("metadata: getting issues from API - project: %s env: %s age: 1h\n", project.Slug, env)
-						issues1h, err := c.sentryAPI.Issues(org.Slug, project, env, "1h")
-						if err != nil {
-							log.Printf("Failed to fetch issues for project %s, env %s, age 1h: %v\n", project.Slug, env, err)
-							continue
-						}
-						// projectsIssueData[project.Slug][env]["1h"] = issues1h["all"].([]interface{})
-						// 确保map的每一层都已初始化
-						if _, ok := projectsIssueData[project.Slug]; !ok {
-							projectsIssueData[project.Slug] = make(map[string]map[string][]interface{})
-						}
-						if _, ok := projectsIssueData[project.Slug][env]; !ok {
-							projectsIssueData[project.Slug][env] = make(map[string][]interface{})
-						}
-						// 确保allIssues是一个切片，然后赋值
-						allIssues, ok := issues1h["all"].([]interface{})
-						if !ok {
-							log.Printf("Unexpected type for 'all' key in issues data, expected []interface{} but got %T", issues1h["all"])
-							continue
-						}
-						projectsIssueData[project.Slug][env]["1h"] = allIssues
-					}
-
-					if c.get24hMetrics {
-						log.Printf("metadata: getting issues from API - project: %s env: %s age: 24h\n", project.Slug, env)
-						issues24h, err := c.sentryAPI.Issues(org.Slug, project, env, "24h")
-						if err != nil {
-							log.Printf("Failed to fetch issues for project %s, env %s, age 24h: %v\n", project.Slug, env, err)
-							continue
-						}
-						// 确保map的每一层都已初始化
-						if _, ok := projectsIssueData[project.Slug]; !ok {
-							projectsIssueData[project.Slug] = make(map[string]map[string][]interface{})
-						}
-						if _, ok := projectsIssueData[project.Slug][env]; !ok {
-							projectsIssueData[project.Slug][env] = make(map[string][]interface{})
-						}
-						// 确保allIssues是一个切片，然后赋值
-						allIssues, ok := issues24h["all"].([]interface{})
-						if !ok {
-							log.Printf("Unexpected type for 'all' key in issues data, expected []interface{} but got %T", issues24h["all"])
-							continue
-						}
-						projectsIssueData[project.Slug][env]["24h"] = allIssues
-						// projectsIssueData[project.Slug][env]["24h"] = issues24h["all"].([]interface{})
-					}
-
-					if c.get14dMetrics {
-						log.Printf("metadata: getting issues from API - project: %s env: %s age: 14d\n", project.Slug, env)
-						issues14d, err := c.sentryAPI.Issues(org.Slug, project, env, "14d")
-						if err != nil {
-							log.Printf("Failed to fetch issues for project %s, env %s, age 14d: %v\n", project.Slug, env, err)
-							continue
-						}
-						// projectsIssueData[project.Slug][env]["14d"] = issues14d["all"].([]interface{})
-						// 确保map的每一层都已初始化
-						if _, ok := projectsIssueData[project.Slug]; !ok {
-							projectsIssueData[project.Slug] = make(map[string]map[string][]interface{})
-						}
-						if _, ok := projectsIssueData[project.Slug][env]; !ok {
-							projectsIssueData[project.Slug][env] = make(map[string][]interface{})
-						}
-						// 确保allIssues是一个切片，然后赋值
-						allIssues, ok := issues14d["all"].([]interface{})
-						if !ok {
-							log.Printf("Unexpected type for 'all' key in issues data, expected []interface{} but got %T", issues14d["all"])
-							continue
-						}
-						projectsIssueData[project.Slug][env]["14d"] = allIssues
-					}
-				}
-				data["projects_data"] = projectsIssueData
+	}
+	if !discover {
+		return c.sentryOrgsSlug, nil
+	}
+
+	log.Printf("metadata: no organizations specified, loading from API\n")
+	orgs, err := c.sentryAPI.Organizations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var orgSlugs []string
+	for _, org := range orgs {
+		orgSlugs = append(orgSlugs, org.Slug)
+	}
+	log.Printf("metadata: organizations loaded from API: %d\n", len(orgSlugs))
+	return orgSlugs, nil
+}
+
+// buildSnapshot 从 Sentry API 构建所有组织的数据, 返回 snapshot 以及其中完整刷新的组织数
+// ctx 超时或取消时, 正在构建的组织只使用已完成的采集步骤, 其余步骤和尚未开始的组织沿用当前 snapshot 中的数据,
+// 之前没有数据的组织不输出; 没有获取到任何新数据时返回错误
+func (c *SentryCollector) buildSnapshot(ctx context.Context, start time.Time) (*Snapshot, int, error) {
+	orgSlugs, err := c.discoverOrgs(ctx)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to fetch organizations: %v", err)
+	}
+
+	snap := &Snapshot{}
+	refreshed, updated := 0, 0
+	for _, orgSlug := range orgSlugs {
+		var data *sentryData
+		complete := false
+		if ctx.Err() == nil {
+			data, complete = c.buildOrgDataFromAPI(ctx, orgSlug)
+		}
+		switch {
+		case complete:
+			data.RefreshedAt = start.Unix()
+			refreshed++
+			updated++
+		case data != nil:
+			// 部分采集步骤完成, RefreshedAt 为沿用的数据的刷新时间
+			if data.RefreshedAt == 0 {
+				data.RefreshedAt = start.Unix()
 			}
+			updated++
+		case ctx.Err() != nil:
+			data = c.previousOrg(orgSlug)
 		}
-		log.Printf("metadata: projects loaded from API: %d\n", len(data["metadata"].(map[string]interface{})["projects"].([]interface{})))
+		if data == nil {
+			continue
+		}
+		snap.Orgs = append(snap.Orgs, data)
+	}
+	if updated == 0 {
+		if err := ctx.Err(); err != nil {
+			return nil, 0, fmt.Errorf("refresh interrupted before any organization was refreshed: %v", err)
+		}
+		return nil, 0, fmt.Errorf("no organization data loaded from API")
 	}
-	// 写入缓存
-	writeCache(JSONCacheFile, data, time.Now().Add(DefaultCacheExpireTimestamp).Unix())
-	return data
+	return snap, refreshed, nil
 }
 
-// buildSentryData 从缓存中读取数据
-func (c *SentryCollector) buildSentryData() map[string]interface{} {
-	data, err := getCached(JSONCacheFile)
-	if err != nil {
-		//if c.sentryAPI.liveness() {
-		//	log.Printf("cache: %s not found, but API is live. Rebuilding from API...\n", JSONCacheFile)
-		//	apiData := c.buildSentryDataFromAPI()
-		//	return apiData
-		log.Printf("cache: %s not found, but API is not live. Using cached data...\n", JSONCacheFile)
+// previousOrg 返回当前 snapshot 中组织的数据, 没有时返回 nil
+func (c *SentryCollector) previousOrg(orgSlug string) *sentryData {
+	snap := c.snapshot.Load()
+	if snap == nil {
 		return nil
 	}
-	if data == nil {
-		log.Printf("cache: %s not found.\n", JSONCacheFile)
-		log.Printf("cache: rebuilding from API...\n")
-		apiData := c.buildSentryDataFromAPI()
-		return apiData
+	for _, data := range snap.Orgs {
+		if data.Org.Slug != orgSlug {
+			continue
+		}
+		// 旧版本保存的 snapshot 中没有组织的刷新时间, 已发布的数据不能修改, 复制后再补上
+		if data.RefreshedAt == 0 {
+			copied := *data
+			copied.RefreshedAt = snap.CreatedAt
+			return &copied
+		}
+		return data
 	}
-	log.Printf("cache: reading data structure from file: %s\n", JSONCacheFile)
-	return data
+	return nil
 }
 
-// Describe 方法用于描述所有收集器的指标
-func (c *SentryCollector) Describe(ch chan<- *prometheus.Desc) {
-	prometheus.DescribeByCollect(c, ch)
-}
+// buildOrgDataFromAPI 从 Sentry API 构建单个组织的数据, 失败时返回 nil
+// ctx 在采集过程中超时或取消时 complete 为 false, 未完成的采集步骤沿用上一次的数据;
+// 组织、项目和环境信息没有获取完整时返回 nil
+func (c *SentryCollector) buildOrgDataFromAPI(ctx context.Context, orgSlug string) (data *sentryData, complete bool) {
+	// 获取组织信息
+	org, err := c.sentryAPI.GetOrg(ctx, orgSlug)
+	if err != nil {
+		log.Printf("Failed to fetch organization %s: %v\n", orgSlug, err)
+		return nil, false
+	}
+	log.Printf("metadata: sentry organization: %s\n", org.Slug)
 
-// Collect 方法用于收集指标
-func (c *SentryCollector) Collect(ch chan<- prometheus.Metric) {
-	// 拿到缓存的数据
-	data := c.buildSentryData()
-	metadata := data["metadata"].(map[string]interface{})
-	projectsData, ok := data["projects_data"].(map[string]interface{})
-	if !ok {
-		log.Println("Type assertion failed for data[\"projects_data\"].")
-		return
+	// 获取组织下的全部项目, 再按过滤规则筛选
+	allProjects, err := c.sentryAPI.Projects(ctx, org.Slug)
+	if err != nil {
+		log.Printf("Failed to fetch projects for organization %s: %v\n", org.Slug, err)
+		return nil, false
 	}
-	// 获取组织和项目信息
-	c.org = metadata["org"].(map[string]interface{})
-	c.projectsData = projectsData
+	projectsTeams := c.projectTeams(ctx, org, allProjects)
+	projects := c.projectFilter.Filter(allProjects, projectsTeams)
+	log.Printf("metadata: projects matched filters for organization %s: %d/%d\n", org.Slug, len(projects), len(allProjects))
 
-	// 收集问题指标
-	if c.issueMetrics {
-		// 创建一个直方图指标，用于记录每个项目及环境的未解决问题数量分布
-		issuesHistogramMetrics := prometheus.NewHistogramVec(
-			prometheus.HistogramOpts{
-				Name:    "sentry_open_issues_histogram",
-				Help:    "Histogram of open issues (aka is:unresolved) count per project and environment",
-				Buckets: []float64{1, 5, 10, 50, 100, 500}, // 自定义的桶边界，根据实际情况调整
-			},
-			[]string{"project_slug", "environment"},
-		)
+	// 初始化数据结构
+	data = &sentryData{
+		Org:              org,
+		Projects:         projects,
+		ProjectsSlug:     []string{},
+		ProjectsEnvs:     make(map[string][]string),
+		ProjectsFiltered: len(projects) < len(allProjects),
+		ProjectsTeams:    make(map[string][]string),
+	}
+	for _, project := range projects {
+		if teams, ok := projectsTeams[project.Slug]; ok {
+			data.ProjectsTeams[project.Slug] = teams
+		}
+	}
 
-		log.Printf("collector: loading projects issues\n")
-		for _, project := range metadata["projects"].([]interface{}) {
-			projectMap := project.(map[string]interface{})
-			projectSlug := projectMap["slug"].(string)
-			envsInterface := metadata["projects_envs"].(map[string]interface{})[projectSlug].([]interface{})
+	// 并发获取项目环境信息
+	envs := make([][]string, len(projects))
+	envsOK := make([]bool, len(projects))
+	c.parallel(len(projects), func(i int) {
+		projectEnvs, err := c.sentryAPI.Environments(ctx, org.Slug, projects[i])
+		if err != nil {
+			log.Printf("Failed to fetch environments for project %s: %v\n", projects[i].Slug, err)
+			return
+		}
+		envs[i], envsOK[i] = projectEnvs, true
+	})
+	for i, project := range projects {
+		data.ProjectsSlug = append(data.ProjectsSlug, project.Slug)
+		if envsOK[i] {
+			data.ProjectsEnvs[project.Slug] = envs[i]
+		}
+	}
+	log.Printf("metadata: projects loaded from API for organization %s: %d\n", org.Slug, len(data.Projects))
 
-			// 将 []interface{} 类型的 envsInterface 转换为 []string 类型的 envs
-			var envs []string
-			for _, env := range envsInterface {
-				envs = append(envs, env.(string))
-			}
+	if ctx.Err() != nil {
+		return nil, false
+	}
 
-			projectIssues := projectsData[projectSlug].(map[string]interface{})
-			for _, env := range envs {
-				log.Printf("collector: loading issues - project: %s env: %s\n", projectSlug, env)
-				projectIssuesEnvInterface := projectIssues[env]
-				if projectIssuesEnvInterface == nil {
-					log.Printf("No issues data for project: %s env: %s\n", projectSlug, env)
-					continue
-				}
+	// 依次执行各采集步骤, ctx 超时或取消后未完成的步骤沿用上一次的数据
+	prev := c.previousOrg(org.Slug)
+	if prev == nil {
+		prev = &sentryData{}
+	}
+	complete = true
+	for _, step := range c.orgSteps() {
+		if !step.enabled {
+			continue
+		}
+		started := ctx.Err() == nil
+		if started {
+			step.fetch(ctx, data)
+		}
+		if ctx.Err() == nil {
+			continue
+		}
+		complete = false
+		if !started || !step.partialOK {
+			step.carry(data, prev)
+		}
+	}
+	if !complete {
+		data.RefreshedAt = prev.RefreshedAt
+	}
+	return data, complete
+}
 
-				projectIssuesEnv := projectIssuesEnvInterface.(map[string]interface{})
-				//fmt.Println(projectIssuesEnv)
-
-				projectIssues1hInterface := projectIssuesEnv["1h"]
-				projectIssues24hInterface := projectIssuesEnv["24h"]
-				projectIssues14dInterface := projectIssuesEnv["14d"]
-
-				if projectIssues1hInterface != nil {
-					projectIssues1h := projectIssues1hInterface.([]interface{})
-					events1h := 0
-					for _, issue := range projectIssues1h {
-						issueMap := issue.(map[string]interface{})
-						switch v := issueMap["count"].(type) {
-						case float64:
-							events1h += int(v)
-						case string:
-							floatVal, err := strconv.ParseFloat(v, 64)
-							if err != nil {
-								log.Printf("Failed to parse count as float64: %v\n", err)
-								continue
-							}
-							events1h += int(floatVal)
-						default:
-							log.Printf("Unexpected type for 'count': %T\n", v)
-							continue
-						}
-					}
-					issuesHistogramMetrics.WithLabelValues(
-						projectSlug,
-						env,
-					).Observe(float64(events1h))
-				} else {
-					log.Printf("No 1h issues data for project: %s env: %s\n", projectSlug, env)
-				}
+// orgStep 构建组织数据的一个采集步骤
+type orgStep struct {
+	enabled bool
+	// fetch 从 Sentry API 获取本步骤的数据并填充到 data
+	fetch func(ctx context.Context, data *sentryData)
+	// carry 从上一次的数据中复制本步骤的字段, 用于刷新超时后未完成的步骤
+	carry func(data, prev *sentryData)
+	// partialOK 为 true 表示中途超时得到的数据仍然可以使用
+	partialOK bool
+}
 
-				if projectIssues24hInterface != nil {
-					projectIssues24h := projectIssues24hInterface.([]interface{})
-					events24h := 0
-					for _, issue := range projectIssues24h {
-						issueMap := issue.(map[string]interface{})
-						switch v := issueMap["count"].(type) {
-						case float64:
-							events24h += int(v)
-						case string:
-							floatVal, err := strconv.ParseFloat(v, 64)
-							if err != nil {
-								log.Printf("Failed to parse count as float64: %v\n", err)
-								continue
-							}
-							events24h += int(floatVal)
-						default:
-							log.Printf("Unexpected type for 'count': %T\n", v)
-							continue
-						}
-					}
-					issuesHistogramMetrics.WithLabelValues(
-						projectSlug,
-						env,
-					).Observe(float64(events24h))
-
-				} else {
-					log.Printf("No 24h issues data for project: %s env: %s\n", projectSlug, env)
+// orgSteps 返回构建组织数据的采集步骤, 按执行顺序排列
+func (c *SentryCollector) orgSteps() []orgStep {
+	return []orgStep{
+		{
+			enabled: c.opts.IssueMetrics,
+			fetch: func(ctx context.Context, data *sentryData) {
+				c.syncIssues(ctx, data, c.previousIssues(data.Org.Slug))
+			},
+			carry: func(data, prev *sentryData) { data.Issues = prev.Issues },
+			// 请求失败的项目/环境沿用上一次的数据且不推进水位线, 中途超时得到的问题索引同样有效,
+			// 保存下来使下一次刷新可以继续增量同步
+			partialOK: true,
+		},
+		{
+			enabled: c.opts.EventsMetrics,
+			fetch:   c.fetchProjectsStats,
+			carry:   func(data, prev *sentryData) { data.ProjectsStats = prev.ProjectsStats },
+		},
+		{
+			enabled: c.opts.RateLimitMetrics,
+			fetch:   c.fetchProjectsRateLimit,
+			carry:   func(data, prev *sentryData) { data.ProjectsRateLimit = prev.ProjectsRateLimit },
+		},
+		{
+			enabled: c.opts.SessionMetrics,
+			fetch: func(ctx context.Context, data *sentryData) {
+				sessions, err := c.fetchSessions(ctx, data)
+				if err != nil {
+					log.Printf("Failed to fetch sessions for organization %s: %v\n", data.Org.Slug, err)
 				}
+				data.Sessions = sessions
+			},
+			carry: func(data, prev *sentryData) { data.Sessions = prev.Sessions },
+		},
+		{
+			enabled: c.opts.OutcomeMetrics,
+			fetch:   c.fetchOutcomes,
+			carry:   func(data, prev *sentryData) { data.Outcomes = prev.Outcomes },
+		},
+		{
+			enabled: c.opts.TransactionMetrics,
+			fetch:   c.fetchTransactions,
+			carry:   func(data, prev *sentryData) { data.Transactions = prev.Transactions },
+		},
+		{
+			enabled: c.opts.ReleaseMetrics,
+			fetch:   c.fetchReleases,
+			carry: func(data, prev *sentryData) {
+				data.Releases, data.ReleaseDeploys = prev.Releases, prev.ReleaseDeploys
+			},
+		},
+		{
+			enabled: c.opts.MonitorMetrics,
+			fetch:   c.fetchMonitors,
+			carry: func(data, prev *sentryData) {
+				data.Monitors, data.MonitorCheckIns = prev.Monitors, prev.MonitorCheckIns
+			},
+		},
+		{
+			enabled: c.opts.AlertMetrics,
+			fetch:   c.fetchAlerts,
+			carry: func(data, prev *sentryData) {
+				data.AlertRules, data.Incidents = prev.AlertRules, prev.Incidents
+			},
+		},
+		{
+			enabled: c.opts.IssueAlertMetrics,
+			fetch:   c.fetchIssueAlerts,
+			carry:   func(data, prev *sentryData) { data.IssueAlerts = prev.IssueAlerts },
+		},
+	}
+}
 
-				if projectIssues14dInterface != nil {
-					projectIssues14d := projectIssues14dInterface.([]interface{})
-					events14d := 0
-					for _, issue := range projectIssues14d {
-						issueMap := issue.(map[string]interface{})
-						switch v := issueMap["count"].(type) {
-						case float64:
-							events14d += int(v)
-						case string:
-							floatVal, err := strconv.ParseFloat(v, 64)
-							if err != nil {
-								log.Printf("Failed to parse count as float64: %v\n", err)
-								continue
-							}
-							events14d += int(floatVal)
-						default:
-							log.Printf("Unexpected type for 'count': %T\n", v)
-							continue
-						}
-					}
-
-					issuesHistogramMetrics.WithLabelValues(
-						projectSlug,
-						env,
-					).Observe(float64(events14d))
-				} else {
-					log.Printf("No 14d issues data for project: %s env: %s\n", projectSlug, env)
-				}
-			}
+// projectTeams 汇总项目所属团队: 项目自带的 teams 字段以及 organizations/{org}/teams/ 中的团队项目
+func (c *SentryCollector) projectTeams(ctx context.Context, org *sentry.Organization, projects []sentry.Project) map[string][]string {
+	projectsTeams := make(map[string][]string)
+	seen := make(map[string]map[string]bool)
+	add := func(projectSlug, teamSlug string) {
+		if _, ok := seen[projectSlug]; !ok {
+			seen[projectSlug] = make(map[string]bool)
+		}
+		if seen[projectSlug][teamSlug] {
+			return
 		}
+		seen[projectSlug][teamSlug] = true
+		projectsTeams[projectSlug] = append(projectsTeams[projectSlug], teamSlug)
+	}
 
-		issuesHistogramMetrics.Collect(ch)
+	for _, project := range projects {
+		for _, team := range project.Teams {
+			add(project.Slug, team.Slug)
+		}
+	}
+	teams, err := c.sentryAPI.Teams(ctx, org.Slug)
+	if err != nil {
+		log.Printf("Failed to fetch teams: %v\n", err)
 	}
+	for _, team := range teams {
+		for _, project := range team.Projects {
+			add(project.Slug, team.Slug)
+		}
+	}
+	for _, teamSlugs := range projectsTeams {
+		sort.Strings(teamSlugs)
+	}
+	return projectsTeams
+}
 
-	// 收集 open issue events 指标
-	if c.issueMetrics {
-		issuesMetrics := prometheus.NewGaugeVec(
-			prometheus.GaugeOpts{
-				Name: "sentry_open_issue_events",
-				Help: "Number of open issues (aka is:unresolved) per project",
-			},
-			[]string{
-				"issue_id",
-				"logger",
-				"level",
-				"status",
-				"platform",
-				"project_slug",
-				"environment",
-				"release",
-				"isUnhandled",
-				"firstSeen",
-				"lastSeen",
-			},
-		)
+// fetchProjectsStats 并发获取每个项目本月的事件统计
+func (c *SentryCollector) fetchProjectsStats(ctx context.Context, data *sentryData) {
+	stats := make([]sentry.Stats, len(data.Projects))
+	ok := make([]bool, len(data.Projects))
+	c.parallel(len(data.Projects), func(i int) {
+		project := data.Projects[i]
+		events, err := c.sentryAPI.ProjectStats(ctx, data.Org.Slug, project.Slug)
+		if err != nil {
+			log.Printf("Failed to fetch project stats for project %s: %v\n", project.Slug, err)
+			return
+		}
+		stats[i], ok[i] = events, true
+	})
+
+	data.ProjectsStats = make(map[string]sentry.Stats)
+	for i, project := range data.Projects {
+		if ok[i] {
+			data.ProjectsStats[project.Slug] = stats[i]
+		}
+	}
+}
 
-		for _, project := range metadata["projects"].([]interface{}) {
-			projectMap := project.(map[string]interface{})
-			projectSlug := projectMap["slug"].(string)
+// fetchProjectsRateLimit 并发获取每个项目的限流配置
+func (c *SentryCollector) fetchProjectsRateLimit(ctx context.Context, data *sentryData) {
+	limits := make([]float64, len(data.Projects))
+	ok := make([]bool, len(data.Projects))
+	c.parallel(len(data.Projects), func(i int) {
+		project := data.Projects[i]
+		rateLimitSecond, err := c.sentryAPI.RateLimit(ctx, data.Org.Slug, project.Slug)
+		if err != nil {
+			log.Printf("Failed to fetch rate limit for project %s: %v\n", project.Slug, err)
+			return
+		}
+		limits[i], ok[i] = rateLimitSecond, true
+	})
 
-			envsInterface, ok := metadata["projects_envs"].(map[string]interface{})[projectSlug].([]interface{})
-			if !ok {
-				log.Printf("Failed to assert environments as []interface{}: %v\n", metadata["projects_envs"].(map[string]interface{})[projectSlug])
-				continue
-			}
+	data.ProjectsRateLimit = make(map[string]float64)
+	for i, project := range data.Projects {
+		if ok[i] {
+			data.ProjectsRateLimit[project.Slug] = limits[i]
+		}
+	}
+}
 
-			var envs []string
-			for _, env := range envsInterface {
-				envStr, ok := env.(string)
-				if !ok {
-					log.Printf("Failed to assert environment as string: %v\n", env)
-					continue
-				}
-				envs = append(envs, envStr)
-			}
+// Describe 不发送任何描述, SentryCollector 作为 unchecked collector 注册,
+// 指标集合随 snapshot 变化 (如新增的事务字段), 不需要事先声明
+func (c *SentryCollector) Describe(ch chan<- *prometheus.Desc) {
+}
 
-			projectIssues := projectsData[projectSlug].(map[string]interface{})
-			for _, env := range envs {
-				projectIssuesEnvInterface := projectIssues[env]
-				if projectIssuesEnvInterface == nil {
-					log.Printf("No issues data for project: %s env: %s\n", projectSlug, env)
-					continue
-				}
-				projectIssuesEnv := projectIssuesEnvInterface.(map[string]interface{})
-
-				timeFrames := []string{"1h", "24h", "14d"}
-				for _, timeFrame := range timeFrames {
-					projectIssuesTimeFrameInterface, ok := projectIssuesEnv[timeFrame]
-					if !ok {
-						log.Printf("No %s issues data for project: %s env: %s\n", timeFrame, projectSlug, env)
-						continue
-					}
-					projectIssuesTimeFrame := projectIssuesTimeFrameInterface.([]interface{})
-					for _, issue := range projectIssuesTimeFrame {
-						issueMap := issue.(map[string]interface{})
-						release, err := c.sentryAPI.IssueRelease(issueMap["id"].(string), env)
-						if err != nil {
-							log.Printf("Failed to fetch release for issue %s: %v\n", issueMap["id"].(string), err)
-							continue
-						}
-
-						count, ok := issueMap["count"].(float64)
-						if !ok {
-							countStr, ok := issueMap["count"].(string)
-							if ok {
-								count, err = strconv.ParseFloat(countStr, 64)
-								if err != nil {
-									log.Printf("Failed to parse count as float64: %v\n", err)
-									continue
-								}
-							} else {
-								log.Printf("Unexpected type for 'count': %T\n", issueMap["count"])
-								continue
-							}
-						}
-
-						id, ok := issueMap["id"].(string)
-						if !ok {
-							log.Println("Type assertion failed for issueMap[\"id\"].")
-							return
-						}
-						logger, ok := issueMap["logger"].(string)
-						if !ok {
-							log.Println("Type assertion failed for issueMap[\"logger\"].")
-							return
-						}
-						level, ok := issueMap["level"].(string)
-						if !ok {
-							log.Println("Type assertion failed for issueMap[\"level\"].")
-							return
-						}
-						status, ok := issueMap["status"].(string)
-						if !ok {
-							log.Println("Type assertion failed for issueMap[\"status\"].")
-							return
-						}
-						platform, ok := issueMap["platform"].(string)
-						if !ok {
-							log.Println("Type assertion failed for issueMap[\"platform\"].")
-							return
-						}
-						isUnhandled, ok := issueMap["isUnhandled"]
-						if !ok {
-							log.Println("Key 'isUnhandled' not found in issueMap.")
-							return
-						}
-						firstSeen, ok := issueMap["firstSeen"]
-						if !ok {
-							log.Println("Key 'firstSeen' not found in issueMap.")
-							return
-						}
-						lastSeen, ok := issueMap["lastSeen"]
-						if !ok {
-							log.Println("Key 'lastSeen' not found in issueMap.")
-							return
-						}
-
-						issuesMetrics.WithLabelValues(
-							id,
-							logger,
-							level,
-							status,
-							platform,
-							projectSlug,
-							env,
-							release,
-							fmt.Sprintf("%v", isUnhandled),
-							fmt.Sprintf("%v", firstSeen),
-							fmt.Sprintf("%v", lastSeen),
-						).Set(count)
-					}
-				}
-			}
+// Collect 方法用于收集指标, 只读取当前的 snapshot, 不访问 Sentry API
+// 组织的数据超过 MaxStaleness 时只输出该组织的新鲜度指标, 不影响其他组织
+func (c *SentryCollector) Collect(ch chan<- prometheus.Metric) {
+	if d := c.refreshDuration.Load(); d > 0 {
+		ch <- prometheus.MustNewConstMetric(snapshotRefreshDurationDesc, prometheus.GaugeValue, time.Duration(d).Seconds())
+	}
+	snap := c.snapshot.Load()
+	if snap == nil {
+		log.Println("collector: no sentry data available yet, skipping scrape")
+		return
+	}
+	for _, data := range snap.Orgs {
+		ch <- prometheus.MustNewConstMetric(snapshotAgeDesc, prometheus.GaugeValue, orgAge(snap, data).Seconds(), data.Org.Slug)
+		if c.tooStale(snap, data) {
+			log.Printf("collector: data of organization %s is older than max staleness %s, dropping its Sentry series\n", data.Org.Slug, c.opts.MaxStaleness)
+			continue
+		}
+		c.collectOrg(ch, data)
+	}
+}
+
+// collectOrg 收集单个组织的指标, 所有指标都带有 organization 标签
+func (c *SentryCollector) collectOrg(ch chan<- prometheus.Metric, data *sentryData) {
+	// 收集项目信息指标, 用于通过 group_left 为其他指标关联团队
+	projectInfoMetrics := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_project_info",
+			Help: "Project information with owning team, one series per team, value is always 1",
+		},
+		[]string{"organization", "project_slug", "project_id", "platform", "team"},
+	)
+	for _, project := range data.Projects {
+		teams := data.ProjectsTeams[project.Slug]
+		if len(teams) == 0 {
+			teams = []string{""}
+		}
+		for _, team := range teams {
+			projectInfoMetrics.WithLabelValues(data.Org.Slug, project.Slug, project.ID, project.Platform, team).Set(1)
 		}
+	}
+	projectInfoMetrics.Collect(ch)
 
-		issuesMetrics.Collect(ch)
+	// 收集问题指标
+	if c.opts.IssueMetrics {
+		c.collectIssues(ch, data)
 	}
 
 	// 收集 events 指标
-	if c.eventsMetrics {
+	if c.opts.EventsMetrics {
 		projectEventsMetrics := prometheus.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "sentry_events",
 				Help: "Total events counts per project",
 			},
-			[]string{"project_slug", "stat"},
+			[]string{"organization", "project_slug", "stat"},
 		)
 
-		for _, project := range metadata["projects"].([]interface{}) {
-			projectMap := project.(map[string]interface{})
-			projectSlug := projectMap["slug"].(string)
-			events, err := c.sentryAPI.ProjectStats(c.sentryOrgSlug, projectSlug)
-			if err != nil {
-				log.Printf("Failed to fetch project stats for project %s: %v\n", projectSlug, err)
+		for _, project := range data.Projects {
+			events, ok := data.ProjectsStats[project.Slug]
+			if !ok {
 				continue
 			}
 			for stat, value := range events {
 				projectEventsMetrics.WithLabelValues(
-					projectSlug,
+					data.Org.Slug,
+					project.Slug,
 					stat,
 				).Add(float64(value))
 			}
@@ -653,28 +697,61 @@ func (c *SentryCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	// 收集 rate limit 指标
-	if c.rateLimitMetrics {
+	if c.opts.RateLimitMetrics {
 		projectRateMetrics := prometheus.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "sentry_rate_limit_events_sec",
 				Help: "Rate limit events per second for a project",
 			},
-			[]string{"project_slug"},
+			[]string{"organization", "project_slug"},
 		)
 
-		for _, project := range metadata["projects"].([]interface{}) {
-			projectMap := project.(map[string]interface{})
-			projectSlug := projectMap["slug"].(string)
-			rateLimitSecond, err := c.sentryAPI.RateLimit(c.sentryOrgSlug, projectSlug)
-			if err != nil {
-				log.Printf("Failed to fetch rate limit for project %s: %v\n", projectSlug, err)
+		for _, project := range data.Projects {
+			rateLimitSecond, ok := data.ProjectsRateLimit[project.Slug]
+			if !ok {
 				continue
 			}
 			projectRateMetrics.WithLabelValues(
-				projectSlug,
+				data.Org.Slug,
+				project.Slug,
 			).Set(rateLimitSecond)
 		}
 
 		projectRateMetrics.Collect(ch)
 	}
+
+	// 收集会话指标
+	if c.opts.SessionMetrics {
+		c.collectSessions(ch, data)
+	}
+
+	// 收集 stats_v2 outcome 指标
+	if c.opts.OutcomeMetrics {
+		c.collectOutcomes(ch, data)
+	}
+
+	// 收集事务 (performance) 指标
+	if c.opts.TransactionMetrics {
+		c.collectTransactions(ch, data)
+	}
+
+	// 收集发布版本指标
+	if c.opts.ReleaseMetrics {
+		c.collectReleases(ch, data)
+	}
+
+	// 收集 Cron 监控指标
+	if c.opts.MonitorMetrics {
+		c.collectMonitors(ch, data)
+	}
+
+	// 收集指标告警规则和事件指标
+	if c.opts.AlertMetrics {
+		c.collectAlerts(ch, data)
+	}
+
+	// 收集问题告警规则触发指标
+	if c.opts.IssueAlertMetrics {
+		c.collectIssueAlerts(ch, data)
+	}
 }
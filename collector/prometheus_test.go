@@ -0,0 +1,103 @@
+package collector
+
+import (
+	"reflect"
+	"sentry-exporter/sentry"
+	"sort"
+	"testing"
+	"time"
+)
+
+// collectedOrgs 返回 Collect 输出的指标中, 按指标名称分组的 organization 标签取值
+func collectedOrgs(t *testing.T, c *SentryCollector) map[string][]string {
+	t.Helper()
+	orgs := make(map[string][]string)
+	for name, metrics := range gather(t, c.Collect) {
+		for _, metric := range metrics {
+			orgs[name] = append(orgs[name], labels(metric)["organization"])
+		}
+		sort.Strings(orgs[name])
+	}
+	return orgs
+}
+
+func TestCollectStaleOrgs(t *testing.T) {
+	now := time.Now()
+	org := func(slug string, age time.Duration) *sentryData {
+		return &sentryData{
+			RefreshedAt: now.Add(-age).Unix(),
+			Org:         &sentry.Organization{Slug: slug},
+			Projects:    []sentry.Project{{ID: "1", Slug: "web"}},
+		}
+	}
+	tests := []struct {
+		name         string
+		maxStaleness time.Duration
+		orgs         []*sentryData
+		// want 输出了 sentry_project_info 的组织
+		want []string
+	}{
+		{
+			name:         "all fresh",
+			maxStaleness: 30 * time.Minute,
+			orgs:         []*sentryData{org("a", time.Minute), org("b", 2*time.Minute)},
+			want:         []string{"a", "b"},
+		},
+		{
+			name:         "only the stale organization is dropped",
+			maxStaleness: 30 * time.Minute,
+			orgs:         []*sentryData{org("a", time.Minute), org("b", time.Hour)},
+			want:         []string{"a"},
+		},
+		{
+			name:         "staleness disabled",
+			maxStaleness: 0,
+			orgs:         []*sentryData{org("a", time.Minute), org("b", 24*time.Hour)},
+			want:         []string{"a", "b"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := NewSentryCollector(nil, nil, nil, Options{MaxStaleness: tt.maxStaleness})
+			c.snapshot.Store(&Snapshot{Orgs: tt.orgs, CreatedAt: now.Unix()})
+
+			orgs := collectedOrgs(t, c)
+			if got := orgs["sentry_project_info"]; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("organizations with Sentry series = %v, want %v", got, tt.want)
+			}
+			// 无论是否过期, 每个组织都输出数据时长
+			if got, want := orgs["sentry_exporter_snapshot_age_seconds"], []string{"a", "b"}; !reflect.DeepEqual(got, want) {
+				t.Errorf("organizations with snapshot age = %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestLoadSnapshot(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		ages     []time.Duration
+		wantLoad bool
+	}{
+		{name: "fresh", ages: []time.Duration{time.Minute}, wantLoad: true},
+		{name: "one organization stale", ages: []time.Duration{time.Hour, time.Minute}, wantLoad: true},
+		{name: "all organizations stale", ages: []time.Duration{time.Hour, 2 * time.Hour}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap := &Snapshot{CreatedAt: now.Unix()}
+			for _, age := range tt.ages {
+				snap.Orgs = append(snap.Orgs, &sentryData{RefreshedAt: now.Add(-age).Unix(), Org: &sentry.Organization{Slug: "acme"}})
+			}
+			store := NewMemoryStore()
+			store.Save(snap)
+
+			c := NewSentryCollector(nil, nil, nil, Options{MaxStaleness: 30 * time.Minute, Store: store})
+			c.LoadSnapshot()
+			if loaded := c.snapshot.Load() != nil; loaded != tt.wantLoad {
+				t.Errorf("snapshot loaded = %v, want %v", loaded, tt.wantLoad)
+			}
+		})
+	}
+}
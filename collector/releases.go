@@ -0,0 +1,190 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sentry-exporter/sentry"
+	"time"
+)
+
+const (
+	// DefaultReleasesLimit 每个项目/环境默认采集的最近发布版本数
+	DefaultReleasesLimit = 5
+)
+
+// fetchReleases 并发获取每个项目/环境最近若干个发布版本, 再并发获取这些版本的部署记录
+func (c *SentryCollector) fetchReleases(ctx context.Context, data *sentryData) {
+	limit := c.opts.ReleasesLimit
+	if limit <= 0 {
+		limit = DefaultReleasesLimit
+	}
+
+	type job struct {
+		project  sentry.Project
+		env      string
+		releases []sentry.Release
+		ok       bool
+	}
+	data.Releases = make(map[string]map[string][]sentry.Release)
+	var jobs []*job
+	for _, project := range data.Projects {
+		data.Releases[project.Slug] = make(map[string][]sentry.Release)
+		for _, env := range data.ProjectsEnvs[project.Slug] {
+			jobs = append(jobs, &job{project: project, env: env})
+		}
+	}
+	c.parallel(len(jobs), func(i int) {
+		j := jobs[i]
+		log.Printf("metadata: getting releases from API - project: %s env: %s\n", j.project.Slug, j.env)
+		releases, err := c.sentryAPI.ProjectReleasesHealth(ctx, data.Org.Slug, j.project, j.env, limit, c.opts.ReleasesWindow)
+		if err != nil {
+			log.Printf("Failed to fetch releases for project %s, env %s: %v\n", j.project.Slug, j.env, err)
+			return
+		}
+		j.releases, j.ok = releases, true
+	})
+
+	// 同一个版本可能出现在多个项目/环境中, 部署记录只查询一次
+	var versions []string
+	seen := make(map[string]bool)
+	for _, j := range jobs {
+		if !j.ok {
+			continue
+		}
+		data.Releases[j.project.Slug][j.env] = j.releases
+		for _, release := range j.releases {
+			if !seen[release.Version] {
+				seen[release.Version] = true
+				versions = append(versions, release.Version)
+			}
+		}
+	}
+
+	deploys := make([][]sentry.Deploy, len(versions))
+	c.parallel(len(versions), func(i int) {
+		var err error
+		deploys[i], err = c.sentryAPI.ReleaseDeploys(ctx, data.Org.Slug, versions[i])
+		if err != nil {
+			log.Printf("Failed to fetch deploys for release %s: %v\n", versions[i], err)
+		}
+	})
+	data.ReleaseDeploys = make(map[string][]sentry.Deploy)
+	for i, version := range versions {
+		data.ReleaseDeploys[version] = deploys[i]
+	}
+}
+
+// collectReleases 收集每个项目/环境最近若干个发布版本的部署时间、新问题数、adoption 和 crash-free 指标
+func (c *SentryCollector) collectReleases(ch chan<- prometheus.Metric, data *sentryData) {
+	labels := []string{"organization", "project_slug", "environment", "version"}
+	releaseInfo := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_info",
+			Help: "Recent releases per project and environment, value is always 1",
+		},
+		[]string{"organization", "project_slug", "environment", "version", "short_version", "ref"},
+	)
+	firstDeploy := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_first_deploy_timestamp_seconds",
+			Help: "Unix timestamp of the first finished deploy of a release to an environment",
+		},
+		labels,
+	)
+	lastDeploy := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_last_deploy_timestamp_seconds",
+			Help: "Unix timestamp of the last finished deploy of a release to an environment",
+		},
+		labels,
+	)
+	newIssues := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_new_issues",
+			Help: "Number of new issues (newGroups) first seen in a release per project across all environments",
+		},
+		[]string{"organization", "project_slug", "version"},
+	)
+	adoption := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_adoption_ratio",
+			Help: "Ratio of users adopting a release per project and environment in the releases window",
+		},
+		labels,
+	)
+	crashFreeSessions := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_crash_free_sessions_ratio",
+			Help: "Ratio of crash-free sessions of a release per project and environment in the releases window",
+		},
+		labels,
+	)
+	crashFreeUsers := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_release_crash_free_users_ratio",
+			Help: "Ratio of crash-free users of a release per project and environment in the releases window",
+		},
+		labels,
+	)
+
+	for _, project := range data.Projects {
+		for _, env := range data.ProjectsEnvs[project.Slug] {
+			for _, release := range data.Releases[project.Slug][env] {
+				releaseInfo.WithLabelValues(data.Org.Slug, project.Slug, env, release.Version, release.ShortVersion, release.Ref).Set(1)
+
+				if first, last, ok := deployRange(data.ReleaseDeploys[release.Version], env); ok {
+					firstDeploy.WithLabelValues(data.Org.Slug, project.Slug, env, release.Version).Set(float64(first.Unix()))
+					lastDeploy.WithLabelValues(data.Org.Slug, project.Slug, env, release.Version).Set(float64(last.Unix()))
+				}
+
+				for _, releaseProject := range release.Projects {
+					if releaseProject.Slug != project.Slug {
+						continue
+					}
+					// newGroups 为项目在所有环境中的合计, 不区分环境, 同一版本出现在多个环境时只输出一个序列
+					newIssues.WithLabelValues(data.Org.Slug, project.Slug, release.Version).Set(float64(releaseProject.NewGroups))
+					health := releaseProject.HealthData
+					if health == nil || !health.HasHealthData {
+						continue
+					}
+					if health.Adoption != nil {
+						adoption.WithLabelValues(data.Org.Slug, project.Slug, env, release.Version).Set(*health.Adoption / 100)
+					}
+					if health.CrashFreeSessions != nil {
+						crashFreeSessions.WithLabelValues(data.Org.Slug, project.Slug, env, release.Version).Set(*health.CrashFreeSessions / 100)
+					}
+					if health.CrashFreeUsers != nil {
+						crashFreeUsers.WithLabelValues(data.Org.Slug, project.Slug, env, release.Version).Set(*health.CrashFreeUsers / 100)
+					}
+				}
+			}
+		}
+	}
+
+	releaseInfo.Collect(ch)
+	firstDeploy.Collect(ch)
+	lastDeploy.Collect(ch)
+	newIssues.Collect(ch)
+	adoption.Collect(ch)
+	crashFreeSessions.Collect(ch)
+	crashFreeUsers.Collect(ch)
+}
+
+// deployRange 返回发布版本在指定环境中最早和最晚完成的部署时间
+func deployRange(deploys []sentry.Deploy, env string) (first time.Time, last time.Time, ok bool) {
+	for _, deploy := range deploys {
+		if deploy.Environment != env || deploy.DateFinished == nil {
+			continue
+		}
+		finished := *deploy.DateFinished
+		if !ok || finished.Before(first) {
+			first = finished
+		}
+		if !ok || finished.After(last) {
+			last = finished
+		}
+		ok = true
+	}
+	return first, last, ok
+}
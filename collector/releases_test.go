@@ -0,0 +1,92 @@
+package collector
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"sentry-exporter/sentry"
+	"testing"
+)
+
+// collectFunc 将 collectXxx 方法包装为 unchecked collector, 用于注册到测试用的 registry
+type collectFunc func(ch chan<- prometheus.Metric)
+
+func (f collectFunc) Describe(ch chan<- *prometheus.Desc) {}
+
+func (f collectFunc) Collect(ch chan<- prometheus.Metric) { f(ch) }
+
+// gather 返回 collect 输出的指标, 按指标名称分组
+func gather(t *testing.T, collect func(ch chan<- prometheus.Metric)) map[string][]*dto.Metric {
+	t.Helper()
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(collectFunc(collect))
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatalf("Gather() = %v", err)
+	}
+	metrics := make(map[string][]*dto.Metric)
+	for _, family := range families {
+		metrics[family.GetName()] = family.GetMetric()
+	}
+	return metrics
+}
+
+// labels 返回指标的标签
+func labels(metric *dto.Metric) map[string]string {
+	values := make(map[string]string)
+	for _, label := range metric.GetLabel() {
+		values[label.GetName()] = label.GetValue()
+	}
+	return values
+}
+
+func TestCollectReleases(t *testing.T) {
+	adoption := 40.0
+	release := func(version string, newGroups sentry.Count) sentry.Release {
+		return sentry.Release{
+			Version: version,
+			Projects: []sentry.ReleaseProject{
+				{Slug: "web", NewGroups: newGroups, HealthData: &sentry.ReleaseHealth{HasHealthData: true, Adoption: &adoption}},
+				{Slug: "api", NewGroups: 100},
+			},
+		}
+	}
+	data := &sentryData{
+		Org:          &sentry.Organization{Slug: "acme"},
+		Projects:     []sentry.Project{{Slug: "web"}},
+		ProjectsEnvs: map[string][]string{"web": {"production", "staging"}},
+		Releases: map[string]map[string][]sentry.Release{
+			"web": {
+				"production": {release("2.0", 3), release("1.0", 5)},
+				"staging":    {release("2.0", 3)},
+			},
+		},
+	}
+	c := NewSentryCollector(nil, nil, nil, Options{})
+	metrics := gather(t, func(ch chan<- prometheus.Metric) { c.collectReleases(ch, data) })
+
+	// newGroups 不区分环境, 每个项目/版本只输出一个序列, 按环境求和不会重复计算
+	newIssues := make(map[string]float64)
+	for _, metric := range metrics["sentry_release_new_issues"] {
+		l := labels(metric)
+		if _, ok := l["environment"]; ok {
+			t.Errorf("sentry_release_new_issues has an environment label: %v", l)
+		}
+		if l["project_slug"] != "web" {
+			t.Errorf("sentry_release_new_issues for project %s, want only web", l["project_slug"])
+		}
+		newIssues[l["version"]] += metric.GetGauge().GetValue()
+	}
+	if newIssues["2.0"] != 3 || newIssues["1.0"] != 5 || len(newIssues) != 2 {
+		t.Errorf("sentry_release_new_issues = %v, want 2.0: 3, 1.0: 5", newIssues)
+	}
+
+	// adoption 等健康数据仍按环境输出
+	if got := len(metrics["sentry_release_adoption_ratio"]); got != 3 {
+		t.Errorf("sentry_release_adoption_ratio has %d series, want 3", got)
+	}
+	for _, metric := range metrics["sentry_release_adoption_ratio"] {
+		if v := metric.GetGauge().GetValue(); v != 0.4 {
+			t.Errorf("sentry_release_adoption_ratio = %v, want 0.4", v)
+		}
+	}
+}
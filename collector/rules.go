@@ -0,0 +1,109 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"sentry-exporter/sentry"
+)
+
+// DefaultIssueAlertWindows 默认统计问题告警规则触发次数的时间窗口
+var DefaultIssueAlertWindows = []string{"24h"}
+
+// issueAlertStats 单个问题告警规则在一个时间窗口内的触发情况
+type issueAlertStats struct {
+	RuleID   string `json:"rule_id"`
+	RuleName string `json:"rule_name"`
+	Window   string `json:"window"`
+	// Fires 触发次数, Issues 触发过的问题数
+	Fires  float64 `json:"fires"`
+	Issues float64 `json:"issues"`
+}
+
+// fetchIssueAlerts 并发获取每个项目的问题告警规则, 再并发获取每个规则在各时间窗口内的触发次数和触发过的问题数
+func (c *SentryCollector) fetchIssueAlerts(ctx context.Context, data *sentryData) {
+	windows := c.opts.IssueAlertWindows
+	if len(windows) == 0 {
+		windows = DefaultIssueAlertWindows
+	}
+
+	rules := make([][]sentry.IssueAlertRule, len(data.Projects))
+	c.parallel(len(data.Projects), func(i int) {
+		project := data.Projects[i]
+		log.Printf("metadata: getting issue alert rules from API - project: %s\n", project.Slug)
+		projectRules, err := c.sentryAPI.ProjectRules(ctx, data.Org.Slug, project.Slug)
+		if err != nil {
+			log.Printf("Failed to fetch issue alert rules for project %s: %v\n", project.Slug, err)
+			return
+		}
+		rules[i] = projectRules
+	})
+
+	type job struct {
+		project string
+		stats   issueAlertStats
+		ok      bool
+	}
+	var jobs []*job
+	for i, project := range data.Projects {
+		for _, rule := range rules[i] {
+			for _, window := range windows {
+				jobs = append(jobs, &job{
+					project: project.Slug,
+					stats:   issueAlertStats{RuleID: rule.ID, RuleName: rule.Name, Window: window},
+				})
+			}
+		}
+	}
+	c.parallel(len(jobs), func(i int) {
+		j := jobs[i]
+		history, err := c.sentryAPI.RuleGroupHistory(ctx, data.Org.Slug, j.project, j.stats.RuleID, j.stats.Window)
+		if err != nil {
+			log.Printf("Failed to fetch group history for rule %s, window %s: %v\n", j.stats.RuleID, j.stats.Window, err)
+			return
+		}
+		groups := make(map[string]bool)
+		for _, h := range history {
+			j.stats.Fires += float64(h.Count)
+			groups[h.Group.ID] = true
+		}
+		j.stats.Issues = float64(len(groups))
+		j.ok = true
+	})
+
+	data.IssueAlerts = make(map[string][]issueAlertStats)
+	for _, j := range jobs {
+		if j.ok {
+			data.IssueAlerts[j.project] = append(data.IssueAlerts[j.project], j.stats)
+		}
+	}
+}
+
+// collectIssueAlerts 收集每个问题告警规则在各时间窗口内的触发次数和触发过的问题数
+func (c *SentryCollector) collectIssueAlerts(ch chan<- prometheus.Metric, data *sentryData) {
+	labels := []string{"organization", "project_slug", "rule_id", "rule_name", "window"}
+	fires := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_issue_alert_rule_fires",
+			Help: "Number of times an issue alert rule fired in the window",
+		},
+		labels,
+	)
+	issues := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_issue_alert_rule_issues",
+			Help: "Number of distinct issues that triggered an issue alert rule in the window",
+		},
+		labels,
+	)
+
+	for _, project := range data.Projects {
+		for _, stats := range data.IssueAlerts[project.Slug] {
+			fires.WithLabelValues(data.Org.Slug, project.Slug, stats.RuleID, stats.RuleName, stats.Window).Set(stats.Fires)
+			issues.WithLabelValues(data.Org.Slug, project.Slug, stats.RuleID, stats.RuleName, stats.Window).Set(stats.Issues)
+		}
+	}
+
+	fires.Collect(ch)
+	issues.Collect(ch)
+}
@@ -0,0 +1,152 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"strconv"
+)
+
+// sessionStats 单个项目/环境在统计窗口内的会话数据
+type sessionStats struct {
+	// Sessions session.status -> 会话数
+	Sessions map[string]float64 `json:"sessions"`
+	// Users session.status -> 用户数
+	Users map[string]float64 `json:"users"`
+	// TotalUsers 去重后的总用户数 (同一用户可能出现在多个 session.status 中)
+	TotalUsers float64 `json:"total_users"`
+}
+
+// fetchSessions 通过 organizations/{org}/sessions/ 获取会话统计, 返回 project slug -> environment -> 统计
+// 只保留 SentryCollector 已发现的项目和环境
+func (c *SentryCollector) fetchSessions(ctx context.Context, data *sentryData) (map[string]map[string]*sessionStats, error) {
+	projectSlugs := make(map[string]string)
+	var projectIDs []string
+	for _, project := range data.Projects {
+		projectSlugs[project.ID] = project.Slug
+		if data.ProjectsFiltered {
+			projectIDs = append(projectIDs, project.ID)
+		}
+	}
+	knownEnv := func(projectSlug, env string) bool {
+		for _, e := range data.ProjectsEnvs[projectSlug] {
+			if e == env {
+				return true
+			}
+		}
+		return false
+	}
+
+	window := c.opts.SessionsWindow
+	interval := statsInterval(window)
+	byStatus, err := c.sentryAPI.Sessions(ctx, data.Org.Slug, projectIDs,
+		[]string{"sum(session)", "count_unique(user)"},
+		[]string{"project", "environment", "session.status"}, window, interval)
+	if err != nil {
+		return nil, err
+	}
+	totals, err := c.sentryAPI.Sessions(ctx, data.Org.Slug, projectIDs,
+		[]string{"count_unique(user)"},
+		[]string{"project", "environment"}, window, interval)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := make(map[string]map[string]*sessionStats)
+	get := func(projectID int64, env string) *sessionStats {
+		projectSlug, ok := projectSlugs[strconv.FormatInt(projectID, 10)]
+		if !ok || !knownEnv(projectSlug, env) {
+			return nil
+		}
+		if _, ok := stats[projectSlug]; !ok {
+			stats[projectSlug] = make(map[string]*sessionStats)
+		}
+		if _, ok := stats[projectSlug][env]; !ok {
+			stats[projectSlug][env] = &sessionStats{
+				Sessions: make(map[string]float64),
+				Users:    make(map[string]float64),
+			}
+		}
+		return stats[projectSlug][env]
+	}
+
+	for _, group := range byStatus.Groups {
+		st := get(group.By.Project, group.By.Environment)
+		if st == nil {
+			continue
+		}
+		st.Sessions[group.By.SessionStatus] += group.Totals["sum(session)"]
+		st.Users[group.By.SessionStatus] += group.Totals["count_unique(user)"]
+	}
+	for _, group := range totals.Groups {
+		st := get(group.By.Project, group.By.Environment)
+		if st == nil {
+			continue
+		}
+		st.TotalUsers += group.Totals["count_unique(user)"]
+	}
+	return stats, nil
+}
+
+// collectSessions 收集会话 (release health) 指标
+func (c *SentryCollector) collectSessions(ch chan<- prometheus.Metric, data *sentryData) {
+	labels := []string{"organization", "project_slug", "environment"}
+	sessionsTotal := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_sessions_total",
+			Help: "Number of sessions per project, environment and session status in the sessions window",
+		},
+		[]string{"organization", "project_slug", "environment", "session_status"},
+	)
+	crashFreeSessions := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_crash_free_sessions_ratio",
+			Help: "Ratio of sessions that did not crash per project and environment in the sessions window",
+		},
+		labels,
+	)
+	crashFreeUsers := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_crash_free_users_ratio",
+			Help: "Ratio of users that did not experience a crash per project and environment in the sessions window",
+		},
+		labels,
+	)
+	erroredSessions := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_sessions_errored",
+			Help: "Number of errored sessions per project and environment in the sessions window",
+		},
+		labels,
+	)
+	abnormalSessions := prometheus.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "sentry_sessions_abnormal",
+			Help: "Number of abnormal sessions per project and environment in the sessions window",
+		},
+		labels,
+	)
+
+	for projectSlug, envs := range data.Sessions {
+		for env, st := range envs {
+			var total float64
+			for status, count := range st.Sessions {
+				total += count
+				sessionsTotal.WithLabelValues(data.Org.Slug, projectSlug, env, status).Set(count)
+			}
+			erroredSessions.WithLabelValues(data.Org.Slug, projectSlug, env).Set(st.Sessions["errored"])
+			abnormalSessions.WithLabelValues(data.Org.Slug, projectSlug, env).Set(st.Sessions["abnormal"])
+			if total > 0 {
+				crashFreeSessions.WithLabelValues(data.Org.Slug, projectSlug, env).Set(1 - st.Sessions["crashed"]/total)
+			}
+			if st.TotalUsers > 0 {
+				crashFreeUsers.WithLabelValues(data.Org.Slug, projectSlug, env).Set(1 - st.Users["crashed"]/st.TotalUsers)
+			}
+		}
+	}
+
+	sessionsTotal.Collect(ch)
+	crashFreeSessions.Collect(ch)
+	crashFreeUsers.Collect(ch)
+	erroredSessions.Collect(ch)
+	abnormalSessions.Collect(ch)
+}
@@ -0,0 +1,124 @@
+package collector
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+)
+
+// SnapshotSchemaVersion Snapshot 序列化格式的版本, sentryData 等结构不兼容地修改时加 1,
+// 版本不一致的数据在加载时被忽略, 等待下一次刷新重新生成
+const SnapshotSchemaVersion = 2
+
+// SnapshotStore 保存最近一次刷新得到的 Snapshot, 重启或重新加载配置后用于恢复数据
+type SnapshotStore interface {
+	// Load 返回保存的 Snapshot, 没有保存过或 schema 版本不一致时返回 nil, nil
+	Load() (*Snapshot, error)
+	// Save 保存 Snapshot, 调用方保证保存后不再修改 snap
+	Save(snap *Snapshot) error
+}
+
+// storedSnapshot 持久化的 Snapshot 及其 schema 版本
+type storedSnapshot struct {
+	SchemaVersion int       `json:"schema_version"`
+	Snapshot      *Snapshot `json:"snapshot"`
+}
+
+// encodeSnapshot 将 Snapshot 连同 schema 版本编码为 JSON
+func encodeSnapshot(snap *Snapshot) ([]byte, error) {
+	data, err := json.Marshal(storedSnapshot{SchemaVersion: SnapshotSchemaVersion, Snapshot: snap})
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode snapshot: %v", err)
+	}
+	return data, nil
+}
+
+// decodeSnapshot 解码 encodeSnapshot 的结果, schema 版本不一致 (包括旧版本没有版本号的缓存文件) 时返回 nil
+func decodeSnapshot(data []byte) (*Snapshot, error) {
+	var stored storedSnapshot
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to decode snapshot: %v", err)
+	}
+	if stored.SchemaVersion != SnapshotSchemaVersion {
+		return nil, nil
+	}
+	return stored.Snapshot, nil
+}
+
+// MemoryStore 只保存在内存中的 SnapshotStore, 进程重启后数据丢失
+type MemoryStore struct {
+	snap atomic.Pointer[Snapshot]
+}
+
+// NewMemoryStore 创建 MemoryStore
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{}
+}
+
+func (s *MemoryStore) Load() (*Snapshot, error) {
+	return s.snap.Load(), nil
+}
+
+func (s *MemoryStore) Save(snap *Snapshot) error {
+	s.snap.Store(snap)
+	return nil
+}
+
+// FileStore 将 Snapshot 保存为 JSON 文件的 SnapshotStore
+// 先写入同目录下的临时文件再重命名, 读取方不会读到写了一半的文件
+type FileStore struct {
+	path string
+}
+
+// NewFileStore 创建保存到 path 的 FileStore
+func NewFileStore(path string) *FileStore {
+	return &FileStore{path: path}
+}
+
+func (s *FileStore) Load() (*Snapshot, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read snapshot file %s: %v", s.path, err)
+	}
+	snap, err := decodeSnapshot(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", s.path, err)
+	}
+	return snap, nil
+}
+
+func (s *FileStore) Save(snap *Snapshot) error {
+	data, err := encodeSnapshot(snap)
+	if err != nil {
+		return err
+	}
+	file, err := os.CreateTemp(filepath.Dir(s.path), filepath.Base(s.path)+".tmp-*")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot file: %v", err)
+	}
+	tmp := file.Name()
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write snapshot file %s: %v", tmp, err)
+	}
+	if err := file.Sync(); err != nil {
+		file.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("failed to sync snapshot file %s: %v", tmp, err)
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to close snapshot file %s: %v", tmp, err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to rename snapshot file to %s: %v", s.path, err)
+	}
+	return nil
+}
@@ -0,0 +1,117 @@
+package collector
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sentry-exporter/sentry"
+	"testing"
+	"time"
+)
+
+// testSnapshot 返回包含问题索引的 Snapshot, 用于检查存储前后数据一致
+func testSnapshot() *Snapshot {
+	synced := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	return &Snapshot{
+		CreatedAt: synced.Unix(),
+		ExpireAt:  synced.Add(2 * time.Minute).Unix(),
+		Orgs: []*sentryData{{
+			RefreshedAt:  synced.Unix(),
+			Org:          &sentry.Organization{ID: "1", Slug: "acme", Name: "Acme"},
+			Projects:     []sentry.Project{{ID: "11", Slug: "web", Status: "active", Platform: "javascript"}},
+			ProjectsSlug: []string{"web"},
+			ProjectsEnvs: map[string][]string{"web": {"production"}},
+			Issues: &issueIndex{
+				Projects: map[string]map[string]map[string]indexedIssue{
+					"web": {"production": {
+						"100": {Issue: sentry.Issue{ID: "100", FirstSeen: synced.Add(-time.Hour), LastSeen: synced}, Release: "1.0.0", HasRelease: true},
+					}},
+				},
+				MaxAge:     "24h",
+				BuiltAt:    synced,
+				SyncedAt:   synced,
+				FullSyncAt: synced,
+			},
+		}},
+	}
+}
+
+func TestSnapshotStores(t *testing.T) {
+	dir := t.TempDir()
+	db, err := OpenBoltDB(filepath.Join(dir, "sentry-exporter.db"))
+	if err != nil {
+		t.Fatalf("OpenBoltDB() = %v", err)
+	}
+	defer db.Close()
+
+	stores := []struct {
+		name  string
+		store SnapshotStore
+	}{
+		{"memory", NewMemoryStore()},
+		{"file", NewFileStore(filepath.Join(dir, "default.json"))},
+		{"bolt", db.Store("default")},
+	}
+	for _, tt := range stores {
+		t.Run(tt.name, func(t *testing.T) {
+			snap, err := tt.store.Load()
+			if err != nil || snap != nil {
+				t.Fatalf("Load() before Save = %v, %v, want nil, nil", snap, err)
+			}
+
+			want := testSnapshot()
+			if err := tt.store.Save(want); err != nil {
+				t.Fatalf("Save() = %v", err)
+			}
+			got, err := tt.store.Load()
+			if err != nil {
+				t.Fatalf("Load() = %v", err)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Load() = %+v, want %+v", got, want)
+			}
+		})
+	}
+
+	// 同一个数据库中不同实例的数据互不影响
+	if snap, err := db.Store("other").Load(); err != nil || snap != nil {
+		t.Errorf("Load() of another instance = %v, %v, want nil, nil", snap, err)
+	}
+}
+
+func TestDecodeSnapshot(t *testing.T) {
+	data, err := encodeSnapshot(testSnapshot())
+	if err != nil {
+		t.Fatalf("encodeSnapshot() = %v", err)
+	}
+	tests := []struct {
+		name    string
+		data    string
+		wantNil bool
+		wantErr bool
+	}{
+		{name: "current version", data: string(data)},
+		{name: "other version", data: fmt.Sprintf(`{"schema_version": %d, "snapshot": {"orgs": [], "created_at": 1}}`, SnapshotSchemaVersion+1), wantNil: true},
+		{name: "cache file without version", data: `{"orgs": [], "created_at": 1}`, wantNil: true},
+		{name: "invalid JSON", data: `{"orgs": [`, wantNil: true, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			snap, err := decodeSnapshot([]byte(tt.data))
+			if (err != nil) != tt.wantErr || (snap == nil) != tt.wantNil {
+				t.Errorf("decodeSnapshot() = %v, %v, want nil snapshot %v, error %v", snap, err, tt.wantNil, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFileStoreCorrupted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "default.json")
+	if err := os.WriteFile(path, []byte("not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewFileStore(path).Load(); err == nil {
+		t.Errorf("Load() of a corrupted file = nil, want error")
+	}
+}
@@ -0,0 +1,111 @@
+package collector
+
+import (
+	"context"
+	"github.com/prometheus/client_golang/prometheus"
+	"log"
+	"regexp"
+	"sentry-exporter/sentry"
+	"strings"
+)
+
+const (
+	// DefaultTransactionsLimit 每个项目默认采集的事务数 (按 count() 排序的前 N 个)
+	DefaultTransactionsLimit = 20
+)
+
+// DefaultTransactionFields 默认采集的 Discover 聚合列
+var DefaultTransactionFields = []string{
+	"count()",
+	"epm()",
+	"p50(transaction.duration)",
+	"p95(transaction.duration)",
+	"p99(transaction.duration)",
+	"failure_rate()",
+	"apdex()",
+}
+
+var nonMetricNameChars = regexp.MustCompile(`[^a-z0-9]+`)
+
+// fieldMetricSuffix 将 Discover 聚合列转换为指标名后缀, 例如 p95(transaction.duration) -> p95_transaction_duration
+func fieldMetricSuffix(field string) string {
+	return strings.Trim(nonMetricNameChars.ReplaceAllString(strings.ToLower(field), "_"), "_")
+}
+
+// transactionAggregates 返回需要采集的 Discover 聚合列
+func (c *SentryCollector) transactionAggregates() []string {
+	if len(c.opts.TransactionFields) == 0 {
+		return DefaultTransactionFields
+	}
+	return c.opts.TransactionFields
+}
+
+// fetchTransactions 通过 Discover 接口并发获取每个项目 top N 事务的吞吐、耗时分位数、失败率和 apdex
+func (c *SentryCollector) fetchTransactions(ctx context.Context, data *sentryData) {
+	limit := c.opts.TransactionsLimit
+	if limit <= 0 {
+		limit = DefaultTransactionsLimit
+	}
+	fields := append([]string{"transaction", "environment"}, c.transactionAggregates()...)
+
+	rows := make([][]sentry.DiscoverRow, len(data.Projects))
+	ok := make([]bool, len(data.Projects))
+	c.parallel(len(data.Projects), func(i int) {
+		project := data.Projects[i]
+		log.Printf("metadata: getting transactions from API - project: %s\n", project.Slug)
+		result, err := c.sentryAPI.Discover(ctx, data.Org.Slug, []string{project.ID}, fields,
+			"event.type:transaction", "-count()", c.opts.TransactionsWindow, limit)
+		if err != nil {
+			log.Printf("Failed to fetch transactions for project %s: %v\n", project.Slug, err)
+			return
+		}
+		rows[i], ok[i] = result.Data, true
+	})
+
+	data.Transactions = make(map[string][]sentry.DiscoverRow)
+	for i, project := range data.Projects {
+		if ok[i] {
+			data.Transactions[project.Slug] = rows[i]
+		}
+	}
+}
+
+// collectTransactions 收集每个项目 top N 事务的 Discover 聚合指标
+func (c *SentryCollector) collectTransactions(ch chan<- prometheus.Metric, data *sentryData) {
+	aggregates := c.transactionAggregates()
+	metrics := make([]*prometheus.GaugeVec, len(aggregates))
+	for i, field := range aggregates {
+		metrics[i] = prometheus.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "sentry_transaction_" + fieldMetricSuffix(field),
+				Help: "Discover " + field + " per project, environment and transaction in the transactions window",
+			},
+			[]string{"organization", "project_slug", "environment", "transaction"},
+		)
+	}
+
+	for _, project := range data.Projects {
+		for _, row := range data.Transactions[project.Slug] {
+			for i, field := range aggregates {
+				// 旧版本 Sentry 返回的 key 为别名 (如 p95_transaction_duration)
+				value, ok := row.Float(field)
+				if !ok {
+					value, ok = row.Float(fieldMetricSuffix(field))
+				}
+				if !ok {
+					continue
+				}
+				metrics[i].WithLabelValues(
+					data.Org.Slug,
+					project.Slug,
+					row.String("environment"),
+					row.String("transaction"),
+				).Set(value)
+			}
+		}
+	}
+
+	for _, metric := range metrics {
+		metric.Collect(ch)
+	}
+}
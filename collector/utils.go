@@ -1,56 +1,40 @@
 package collector
 
 import (
-	"encoding/json"
-	"errors"
 	"fmt"
-	"log"
-	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
-func writeCache(filename string, data map[string]interface{}, expireTimestamp int64) error {
-	// 将数据存储为 JSON 格式到本地文件
-	data["expire_at"] = expireTimestamp
-	file, err := os.Create(filename)
-	if err != nil {
-		return fmt.Errorf("创建缓存文件失败: %v", err)
+// parseStatsPeriod 解析 Sentry 的 statsPeriod (如 1h、24h、14d), 在 time.ParseDuration 的基础上支持 d (天)
+func parseStatsPeriod(period string) (time.Duration, error) {
+	if strings.HasSuffix(period, "d") {
+		days, err := strconv.Atoi(strings.TrimSuffix(period, "d"))
+		if err != nil {
+			return 0, fmt.Errorf("invalid stats period %q: %v", period, err)
+		}
+		return time.Duration(days) * 24 * time.Hour, nil
 	}
-	defer file.Close()
-
-	err = json.NewEncoder(file).Encode(data)
+	d, err := time.ParseDuration(period)
 	if err != nil {
-		return fmt.Errorf("将数据编码为 JSON 失败: %v", err)
+		return 0, fmt.Errorf("invalid stats period %q: %v", period, err)
 	}
-
-	return nil
+	return d, nil
 }
 
-func getCached(filename string) (map[string]interface{}, error) {
-	// 从本地缓存文件中读取数据
-	file, err := os.Open(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil // 缓存文件不存在
-		}
-		return nil, fmt.Errorf("打开缓存文件失败: %v", err)
-	}
-	defer file.Close()
-
-	var cache map[string]interface{}
-	err = json.NewDecoder(file).Decode(&cache)
-	if err != nil {
-		return nil, fmt.Errorf("解析 JSON 缓存数据失败: %v", err)
+// statsInterval 根据统计窗口选择时间序列的聚合粒度, 避免长窗口返回过多数据点
+func statsInterval(period string) string {
+	d, err := parseStatsPeriod(period)
+	if err == nil && d >= 24*time.Hour {
+		return "1d"
 	}
+	return "1h"
+}
 
-	expireTimestamp, ok := cache["expire_at"].(float64)
-	if !ok {
-		return nil, errors.New("缓存数据中的 expire_at 值无效")
-	}
-	if int64(expireTimestamp) <= time.Now().Unix() {
-		log.Printf("缓存已过期，删除文件: %s\n", filename)
-		return nil, nil // 缓存已过期
+func boolToFloat(b bool) float64 {
+	if b {
+		return 1
 	}
-
-	return cache, nil
+	return 0
 }
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/common/expfmt"
+	"gopkg.in/yaml.v3"
+	"log"
+	"os"
+	"sentry-exporter/collector"
+	"sentry-exporter/config"
+)
+
+// runCheckConfig 校验配置, 并确认每个实例的 token 可以访问配置的组织
+func runCheckConfig(ctx context.Context, path string, cfg *config.Config) error {
+	failed := 0
+	for _, instance := range cfg.Instances {
+		if err := checkInstance(ctx, cfg, instance); err != nil {
+			failed++
+			fmt.Printf("instance %s: FAILED: %v\n", instance.Name, err)
+			continue
+		}
+		fmt.Printf("instance %s: OK\n", instance.Name)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d Sentry instances failed the check", failed, len(cfg.Instances))
+	}
+	return nil
+}
+
+// checkInstance 校验实例的项目过滤规则, 并确认 token 有效且可以访问配置的组织
+func checkInstance(ctx context.Context, cfg *config.Config, instance config.Instance) error {
+	_, err := collector.NewProjectFilter(instance.Projects, instance.ExcludeProjects,
+		instance.ProjectPlatforms, instance.ProjectTeams, instance.IncludeInactiveProjects)
+	if err != nil {
+		return err
+	}
+
+	sentryAPI := newSentryAPI(cfg, instance)
+	orgs, err := sentryAPI.Organizations(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list organizations, check api_base_url and auth_token: %v", err)
+	}
+	for _, orgSlug := range instance.OrgSlugs {
+		if orgSlug == "*" {
+			if len(orgs) == 0 {
+				return fmt.Errorf("no organization is visible to the auth token")
+			}
+			continue
+		}
+		if _, err := sentryAPI.GetOrg(ctx, orgSlug); err != nil {
+			return fmt.Errorf("organization %s is not accessible: %v", orgSlug, err)
+		}
+	}
+	return nil
+}
+
+// runOnce 从 Sentry API 刷新一次所有实例的数据, 以 Prometheus 文本格式输出到标准输出
+// 只使用内存存储, 不读取也不写入配置的 snapshot 存储; 所有实例都刷新失败时返回错误
+func runOnce(ctx context.Context, path string, cfg *config.Config) error {
+	stores, err := openSnapshotStores(config.SnapshotStoreConfig{Type: config.StoreMemory})
+	if err != nil {
+		return err
+	}
+	defer stores.Close()
+	registry, collectors, err := buildCollectors(cfg, stores)
+	if err != nil {
+		return fmt.Errorf("failed to build collectors: %v", err)
+	}
+	failed := 0
+	for i, colle := range collectors {
+		if err := colle.Refresh(ctx); err != nil {
+			log.Printf("Failed to refresh Sentry instance %s: %v\n", cfg.Instances[i].Name, err)
+			failed++
+		}
+	}
+	if failed == len(collectors) {
+		return fmt.Errorf("failed to refresh all %d Sentry instances", failed)
+	}
+	families, err := registry.Gather()
+	if err != nil {
+		return fmt.Errorf("failed to gather metrics: %v", err)
+	}
+	for _, family := range families {
+		if _, err := expfmt.MetricFamilyToText(os.Stdout, family); err != nil {
+			return fmt.Errorf("failed to write metrics: %v", err)
+		}
+	}
+	return nil
+}
+
+// discoveredInstance discover 子命令输出的实例
+type discoveredInstance struct {
+	Instance      string          `yaml:"instance"`
+	Organizations []discoveredOrg `yaml:"organizations"`
+	Error         string          `yaml:"error,omitempty"`
+}
+
+// discoveredOrg discover 子命令输出的组织
+type discoveredOrg struct {
+	Slug     string              `yaml:"slug"`
+	Name     string              `yaml:"name"`
+	Projects []discoveredProject `yaml:"projects"`
+}
+
+// discoveredProject discover 子命令输出的项目
+type discoveredProject struct {
+	Slug         string   `yaml:"slug"`
+	Platform     string   `yaml:"platform"`
+	Status       string   `yaml:"status"`
+	Teams        []string `yaml:"teams,omitempty"`
+	Environments []string `yaml:"environments"`
+}
+
+// runDiscover 以 YAML 格式列出每个实例的 token 可见的组织、项目和环境, 用于编写配置文件
+func runDiscover(ctx context.Context, path string, cfg *config.Config) error {
+	var result []discoveredInstance
+	for _, instance := range cfg.Instances {
+		discovered, err := discoverInstance(ctx, cfg, instance)
+		if err != nil {
+			log.Printf("Failed to discover Sentry instance %s: %v\n", instance.Name, err)
+			discovered.Error = err.Error()
+		}
+		result = append(result, discovered)
+	}
+
+	encoder := yaml.NewEncoder(os.Stdout)
+	encoder.SetIndent(2)
+	defer encoder.Close()
+	return encoder.Encode(result)
+}
+
+// discoverInstance 列出实例的组织、项目和环境, 获取单个项目的环境失败时只记录日志
+func discoverInstance(ctx context.Context, cfg *config.Config, instance config.Instance) (discoveredInstance, error) {
+	discovered := discoveredInstance{Instance: instance.Name}
+	sentryAPI := newSentryAPI(cfg, instance)
+	orgs, err := sentryAPI.Organizations(ctx)
+	if err != nil {
+		return discovered, err
+	}
+	for _, org := range orgs {
+		projects, err := sentryAPI.Projects(ctx, org.Slug)
+		if err != nil {
+			return discovered, fmt.Errorf("failed to fetch projects for organization %s: %v", org.Slug, err)
+		}
+		discoveredOrg := discoveredOrg{Slug: org.Slug, Name: org.Name}
+		for _, project := range projects {
+			envs, err := sentryAPI.Environments(ctx, org.Slug, project)
+			if err != nil {
+				log.Printf("Failed to fetch environments for project %s: %v\n", project.Slug, err)
+			}
+			var teams []string
+			for _, team := range project.Teams {
+				teams = append(teams, team.Slug)
+			}
+			discoveredOrg.Projects = append(discoveredOrg.Projects, discoveredProject{
+				Slug:         project.Slug,
+				Platform:     project.Platform,
+				Status:       project.Status,
+				Teams:        teams,
+				Environments: envs,
+			})
+		}
+		discovered.Organizations = append(discovered.Organizations, discoveredOrg)
+	}
+	return discovered, nil
+}
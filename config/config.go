@@ -1,69 +1,341 @@
 package config
 
 import (
-	"log"
+	"bytes"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/url"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
-var (
-	SentryAPIBaseURL       string
-	SentryAuthToken        string
-	SentryExporterOrgSlug  string
-	SentryExporterProjects string
-	SentryRateLimitMetrics bool
-	SentryIssueMetrics     bool
-	SentryEventsMetrics    bool
-	SentryIssues1H         bool
-	SentryIssues24H        bool
-	SentryIssues14D        bool
-	EXPORTER_PORT          string
+const (
+	// DefaultInstanceName 未配置 instances 和 SENTRY_INSTANCES 时, 由 SENTRY_API_BASE_URL 等变量构成的实例名称
+	DefaultInstanceName = "default"
+	// DefaultAPIBaseURL 实例未配置 api_base_url 时使用的 Sentry API 地址
+	DefaultAPIBaseURL = "https://sentry.io/api/0/"
+	// DefaultBoltPath snapshot_store.type 为 bolt 且未配置 path 时使用的数据库文件
+	DefaultBoltPath = "./sentry-exporter.db"
 )
 
-func init() {
-	// 从环境变量中读取配置
-	SentryAPIBaseURL = os.Getenv("SENTRY_API_BASE_URL")
-	SentryAuthToken = os.Getenv("SENTRY_AUTH_TOKEN")
-	SentryExporterOrgSlug = os.Getenv("SENTRY_EXPORTER_ORG_SLUG")
-	SentryExporterProjects = os.Getenv("SENTRY_EXPORTER_PROJECTS")
-	SentryRateLimitMetrics, _ = strconv.ParseBool(os.Getenv("SENTRY_RATE_LIMIT_METRICS"))
-	SentryIssueMetrics, _ = strconv.ParseBool(os.Getenv("SENTRY_ISSUE_METRICS"))
-	SentryEventsMetrics, _ = strconv.ParseBool(os.Getenv("SENTRY_EVENTS_METRICS"))
-	SentryIssues1H, _ = strconv.ParseBool(os.Getenv("SENTRY_ISSUES_1H"))
-	SentryIssues24H, _ = strconv.ParseBool(os.Getenv("SENTRY_ISSUES_24H"))
-	SentryIssues14D, _ = strconv.ParseBool(os.Getenv("SENTRY_ISSUES_14D"))
-	EXPORTER_PORT = os.Getenv("EXPORTER_PORT")
-
-	if SentryAPIBaseURL == "" {
-		log.Printf("Error: SENTRY_API_BASE_URL environment variable is not set, defaulting to empty string.")
-	}
-	if SentryAuthToken == "" {
-		log.Fatalf("Error: SENTRY_AUTH_TOKEN environment variable is not set.")
-	}
-	if SentryExporterOrgSlug == "" {
-		log.Fatalf("Error: SENTRY_EXPORTER_ORG_SLUG environment variable is not set.")
-	}
-	//if SentryExporterProjects == "" {
-	//	log.Fatalf("Warning: SENTRY_EXPORTER_PROJECTS environment variable is not set.")
-	//}
-	if !SentryRateLimitMetrics {
-		SentryRateLimitMetrics = true
-		log.Printf("Warning: SENTRY_RATE_LIMIT_METRICS is set to false. Rate limit metrics will not be collected.")
-	}
-	if !SentryIssueMetrics {
-		SentryIssueMetrics = true
-		log.Printf("Warning: SENTRY_ISSUE_METRICS is set to false. Issue metrics will not be collected.")
-	}
-	if !SentryEventsMetrics {
-		SentryEventsMetrics = true
-		log.Printf("Warning: SENTRY_EVENTS_METRICS is set to false. Events metrics will not be collected.")
-	}
-	if !SentryIssues1H && !SentryIssues24H && !SentryIssues14D {
-		SentryIssues1H = true
-		log.Printf("Warning: None of SENTRY_ISSUES_1H, SENTRY_ISSUES_24H, or SENTRY_ISSUES_14D is set to true. It's recommended to set at least one of them to true.")
-	}
-	if EXPORTER_PORT == "" {
-		EXPORTER_PORT = "8080"
-		log.Fatalf("Warning: EXPORTER_PORT environment variable is not set. Use the default 8080.")
+// Instance 一个 Sentry 实例 (sentry.io 或自建) 的连接配置
+type Instance struct {
+	Name       string `yaml:"name"`
+	APIBaseURL string `yaml:"api_base_url"`
+	AuthToken  string `yaml:"auth_token"`
+	OrgSlugs   List   `yaml:"org_slugs"`
+	// Projects/ExcludeProjects/ProjectPlatforms/ProjectTeams 为项目匹配规则列表, 支持 glob 和 "re:" 开头的正则表达式
+	Projects                List `yaml:"projects"`
+	ExcludeProjects         List `yaml:"exclude_projects"`
+	ProjectPlatforms        List `yaml:"project_platforms"`
+	ProjectTeams            List `yaml:"project_teams"`
+	IncludeInactiveProjects bool `yaml:"include_inactive_projects"`
+}
+
+// APIConfig 访问 Sentry API 的分页、超时、限流和并发配置
+type APIConfig struct {
+	MaxPages         int           `yaml:"max_pages"`
+	Timeout          time.Duration `yaml:"timeout"`
+	RateLimitReserve int           `yaml:"rate_limit_reserve"`
+	// Concurrency 刷新时每个组织同时进行的请求数, 1 表示逐个请求
+	Concurrency int `yaml:"concurrency"`
+}
+
+// snapshot 的存储方式
+const (
+	StoreMemory = "memory"
+	StoreFile   = "file"
+	StoreBolt   = "bolt"
+)
+
+// SnapshotStoreConfig 每次刷新得到的 snapshot 的存储方式, 重启后从中恢复数据
+// Type 为 memory (不持久化)、file (每个实例一个 JSON 文件) 或 bolt (嵌入式 bbolt 数据库)
+type SnapshotStoreConfig struct {
+	Type string `yaml:"type"`
+	// Path file 为缓存文件所在目录, 默认为当前目录; bolt 为数据库文件路径, 默认为 ./sentry-exporter.db
+	Path string `yaml:"path"`
+}
+
+// MetricsConfig 指标开关以及各类指标的统计窗口
+type MetricsConfig struct {
+	Issues    bool `yaml:"issues"`
+	Events    bool `yaml:"events"`
+	RateLimit bool `yaml:"rate_limit"`
+	Issues1H  bool `yaml:"issues_1h"`
+	Issues24H bool `yaml:"issues_24h"`
+	Issues14D bool `yaml:"issues_14d"`
+	OrgIssues bool `yaml:"org_issues"`
+	// IssuesFullSyncInterval 问题全量同步的间隔, 其间只增量同步 lastSeen 更新的问题, 0 表示每次都全量同步
+	IssuesFullSyncInterval time.Duration `yaml:"issues_full_sync_interval"`
+	Sessions               bool          `yaml:"sessions"`
+	SessionsWindow         string        `yaml:"sessions_window"`
+	Outcomes               bool          `yaml:"outcomes"`
+	OutcomesWindow         string        `yaml:"outcomes_window"`
+	OutcomeCategories      List          `yaml:"outcome_categories"`
+	Transactions           bool          `yaml:"transactions"`
+	TransactionsWindow     string        `yaml:"transactions_window"`
+	TransactionFields      List          `yaml:"transaction_fields"`
+	TransactionsLimit      int           `yaml:"transactions_limit"`
+	Releases               bool          `yaml:"releases"`
+	ReleasesWindow         string        `yaml:"releases_window"`
+	ReleasesLimit          int           `yaml:"releases_limit"`
+	Monitors               bool          `yaml:"monitors"`
+	Alerts                 bool          `yaml:"alerts"`
+	IncidentsWindow        string        `yaml:"incidents_window"`
+	IssueAlerts            bool          `yaml:"issue_alerts"`
+	IssueAlertWindows      List          `yaml:"issue_alert_windows"`
+}
+
+// Config 导出器的完整配置, 由 YAML 配置文件和环境变量合并而成, 环境变量优先
+type Config struct {
+	ExporterPort string `yaml:"exporter_port"`
+	// RefreshInterval 后台从 Sentry API 刷新数据的间隔, RefreshTimeout 为单次刷新的总时限
+	RefreshInterval time.Duration `yaml:"refresh_interval"`
+	RefreshTimeout  time.Duration `yaml:"refresh_timeout"`
+	// MaxStaleness 刷新持续失败时继续输出旧数据的最长时间, 0 表示不限制
+	MaxStaleness  time.Duration       `yaml:"max_staleness"`
+	SnapshotStore SnapshotStoreConfig `yaml:"snapshot_store"`
+	API           APIConfig           `yaml:"api"`
+	Metrics       MetricsConfig       `yaml:"metrics"`
+	Instances     []Instance          `yaml:"instances"`
+}
+
+// FileEnv 指定 YAML 配置文件路径的环境变量, 为空时只使用环境变量
+const FileEnv = "SENTRY_EXPORTER_CONFIG_FILE"
+
+// Default 返回默认配置, 配置文件和环境变量中未出现的字段保持默认值
+// 默认开启问题 (1h 窗口)、事件和限流指标, 其他指标默认关闭
+func Default() *Config {
+	return &Config{
+		ExporterPort:    "8080",
+		RefreshInterval: 2 * time.Minute,
+		RefreshTimeout:  4 * time.Minute,
+		MaxStaleness:    30 * time.Minute,
+		SnapshotStore: SnapshotStoreConfig{
+			Type: StoreFile,
+		},
+		API: APIConfig{
+			MaxPages:    50,
+			Timeout:     30 * time.Second,
+			Concurrency: 4,
+		},
+		Metrics: MetricsConfig{
+			Issues:                 true,
+			Events:                 true,
+			RateLimit:              true,
+			Issues1H:               true,
+			IssuesFullSyncInterval: 30 * time.Minute,
+			SessionsWindow:         "24h",
+			OutcomesWindow:         "24h",
+			TransactionsWindow:     "1h",
+			TransactionsLimit:      20,
+			ReleasesWindow:         "24h",
+			ReleasesLimit:          5,
+			IncidentsWindow:        "24h",
+			IssueAlertWindows:      List{"24h"},
+		},
+	}
+}
+
+// Errors 配置中的所有错误, 一次性全部报告, 避免逐个修改逐个重试
+type Errors []string
+
+func (e Errors) Error() string {
+	return fmt.Sprintf("invalid configuration (%d errors):\n  - %s", len(e), strings.Join(e, "\n  - "))
+}
+
+// add 记录一个错误
+func (e *Errors) add(format string, args ...interface{}) {
+	*e = append(*e, fmt.Sprintf(format, args...))
+}
+
+// err 没有错误时返回 nil
+func (e Errors) err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Load 读取 YAML 配置文件 (path 为空时跳过), 再用环境变量覆盖, 最后校验配置
+// 配置文件字段类型错误、环境变量无法解析以及校验失败都汇总到 Errors 中一起返回
+func Load(path string) (*Config, error) {
+	var errs Errors
+	cfg := Default()
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config file: %v", err)
+		}
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			var typeErr *yaml.TypeError
+			if !errors.As(err, &typeErr) {
+				return nil, fmt.Errorf("failed to parse config file %s: %v", path, err)
+			}
+			for _, e := range typeErr.Errors {
+				errs.add("%s: %s", path, e)
+			}
+		}
+	}
+	cfg.applyEnv(&errs)
+	cfg.validate(&errs)
+	if err := errs.err(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// statsPeriodPattern Sentry statsPeriod 的格式, 如 30m、24h、14d
+var statsPeriodPattern = regexp.MustCompile(`^[1-9][0-9]*[smhd]$`)
+
+// Validate 校验配置, 返回汇总了所有错误的 Errors
+func (cfg *Config) Validate() error {
+	var errs Errors
+	cfg.validate(&errs)
+	return errs.err()
+}
+
+// validate 校验配置并将错误记录到 errs, 实例未配置 api_base_url 时使用 DefaultAPIBaseURL,
+// bolt 存储未配置 path 时使用 DefaultBoltPath
+func (cfg *Config) validate(errs *Errors) {
+	if port, err := strconv.Atoi(cfg.ExporterPort); err != nil || port < 1 || port > 65535 {
+		errs.add("exporter_port (EXPORTER_PORT): %q is not a valid port (1-65535)", cfg.ExporterPort)
+	}
+	if cfg.RefreshInterval <= 0 {
+		errs.add("refresh_interval (SENTRY_REFRESH_INTERVAL): must be greater than 0")
+	}
+	if cfg.RefreshTimeout < 0 {
+		errs.add("refresh_timeout (SENTRY_REFRESH_TIMEOUT): must not be negative, use 0 to disable the timeout")
+	}
+	if cfg.MaxStaleness < 0 {
+		errs.add("max_staleness (SENTRY_MAX_STALENESS): must not be negative, use 0 to keep serving stale data")
+	} else if cfg.MaxStaleness > 0 && cfg.MaxStaleness < cfg.RefreshInterval {
+		errs.add("max_staleness (SENTRY_MAX_STALENESS): %s is shorter than refresh_interval %s, series would be dropped between refreshes", cfg.MaxStaleness, cfg.RefreshInterval)
+	}
+	switch cfg.SnapshotStore.Type {
+	case StoreMemory, StoreFile:
+	case StoreBolt:
+		if cfg.SnapshotStore.Path == "" {
+			cfg.SnapshotStore.Path = DefaultBoltPath
+		}
+	default:
+		errs.add("snapshot_store.type (SENTRY_SNAPSHOT_STORE): %q is not one of %s, %s, %s", cfg.SnapshotStore.Type, StoreMemory, StoreFile, StoreBolt)
+	}
+	if cfg.API.MaxPages < 0 {
+		errs.add("api.max_pages (SENTRY_API_MAX_PAGES): must not be negative, use 0 to follow all pages")
+	}
+	if cfg.API.Timeout <= 0 {
+		errs.add("api.timeout (SENTRY_API_TIMEOUT): must be greater than 0")
+	}
+	if cfg.API.RateLimitReserve < 0 {
+		errs.add("api.rate_limit_reserve (SENTRY_API_RATE_LIMIT_RESERVE): must not be negative")
+	}
+	if cfg.API.Concurrency < 1 {
+		errs.add("api.concurrency (SENTRY_API_CONCURRENCY): must be at least 1")
+	}
+
+	m := cfg.Metrics
+	if m.Issues && !m.Issues1H && !m.Issues24H && !m.Issues14D {
+		errs.add("metrics.issues (SENTRY_ISSUE_METRICS) is enabled but none of metrics.issues_1h, issues_24h, issues_14d (SENTRY_ISSUES_1H/24H/14D) is enabled")
+	}
+	if m.IssuesFullSyncInterval < 0 {
+		errs.add("metrics.issues_full_sync_interval (SENTRY_ISSUES_FULL_SYNC_INTERVAL): must not be negative, use 0 to always fetch all issues")
+	}
+	checkPeriod := func(name, value string) {
+		if !statsPeriodPattern.MatchString(value) {
+			errs.add("%s: %q is not a valid stats period, e.g. 30m, 24h, 14d", name, value)
+		}
+	}
+	checkPeriod("metrics.sessions_window (SENTRY_SESSIONS_WINDOW)", m.SessionsWindow)
+	checkPeriod("metrics.outcomes_window (SENTRY_OUTCOMES_WINDOW)", m.OutcomesWindow)
+	checkPeriod("metrics.transactions_window (SENTRY_TRANSACTIONS_WINDOW)", m.TransactionsWindow)
+	checkPeriod("metrics.releases_window (SENTRY_RELEASES_WINDOW)", m.ReleasesWindow)
+	checkPeriod("metrics.incidents_window (SENTRY_INCIDENTS_WINDOW)", m.IncidentsWindow)
+	for _, window := range m.IssueAlertWindows {
+		checkPeriod("metrics.issue_alert_windows (SENTRY_ISSUE_ALERT_WINDOWS)", window)
+	}
+	if m.TransactionsLimit <= 0 {
+		errs.add("metrics.transactions_limit (SENTRY_TRANSACTIONS_LIMIT): must be greater than 0")
+	}
+	if m.ReleasesLimit <= 0 {
+		errs.add("metrics.releases_limit (SENTRY_RELEASES_LIMIT): must be greater than 0")
+	}
+
+	if len(cfg.Instances) == 0 {
+		errs.add("instances: no Sentry instance configured")
+	}
+	names := make(map[string]bool)
+	for i := range cfg.Instances {
+		instance := &cfg.Instances[i]
+		if instance.Name == "" {
+			errs.add("instances[%d].name: must not be empty", i)
+			continue
+		}
+		if names[instance.Name] {
+			errs.add("instances[%d].name: duplicate Sentry instance %s", i, instance.Name)
+		}
+		names[instance.Name] = true
+		if instance.APIBaseURL == "" {
+			instance.APIBaseURL = DefaultAPIBaseURL
+		}
+		if u, err := url.Parse(instance.APIBaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs.add("instance %s: api_base_url %q is not a valid http(s) URL", instance.Name, instance.APIBaseURL)
+		}
+		if instance.AuthToken == "" {
+			errs.add("instance %s: auth_token is not set (SENTRY_AUTH_TOKEN or SENTRY_INSTANCE_%s_AUTH_TOKEN)", instance.Name, envName(instance.Name))
+		}
+		if len(instance.OrgSlugs) == 0 {
+			errs.add("instance %s: org_slugs is not set, use * for all organizations (SENTRY_EXPORTER_ORG_SLUG or SENTRY_INSTANCE_%s_ORG_SLUG)", instance.Name, envName(instance.Name))
+		}
+	}
+}
+
+// List 字符串列表, 配置文件中可以写成 YAML 序列或逗号分隔的字符串
+type List []string
+
+// UnmarshalYAML 同时支持 [a, b] 和 "a,b" 两种写法
+func (l *List) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		*l = splitList(value.Value)
+		return nil
+	}
+	var items []string
+	if err := value.Decode(&items); err != nil {
+		return err
+	}
+	*l = List(items)
+	return nil
+}
+
+// splitList 将逗号分隔的字符串拆分为列表, 忽略空白项
+// 项中的逗号写作 "\,", 例如正则表达式 re:^api-\d{1\,3}$
+func splitList(value string) []string {
+	var items []string
+	var item strings.Builder
+	add := func() {
+		if s := strings.TrimSpace(item.String()); s != "" {
+			items = append(items, s)
+		}
+		item.Reset()
+	}
+	for i := 0; i < len(value); i++ {
+		switch {
+		case value[i] == '\\' && i+1 < len(value) && value[i+1] == ',':
+			item.WriteByte(',')
+			i++
+		case value[i] == ',':
+			add()
+		default:
+			item.WriteByte(value[i])
+		}
 	}
+	add()
+	return items
 }
@@ -0,0 +1,362 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// validConfig 返回可以通过校验的最小配置
+func validConfig() *Config {
+	cfg := Default()
+	cfg.Instances = []Instance{{Name: "default", AuthToken: "token", OrgSlugs: List{"acme"}}}
+	return cfg
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(cfg *Config)
+		// errors 期望的错误, 每项为错误信息的片段, 为空表示校验通过
+		errors []string
+	}{
+		{
+			name:   "valid",
+			modify: func(cfg *Config) {},
+		},
+		{
+			name:   "invalid port",
+			modify: func(cfg *Config) { cfg.ExporterPort = "http" },
+			errors: []string{"exporter_port"},
+		},
+		{
+			name:   "port out of range",
+			modify: func(cfg *Config) { cfg.ExporterPort = "70000" },
+			errors: []string{"exporter_port"},
+		},
+		{
+			name: "refresh settings",
+			modify: func(cfg *Config) {
+				cfg.RefreshInterval = 0
+				cfg.RefreshTimeout = -time.Second
+			},
+			errors: []string{"refresh_interval", "refresh_timeout"},
+		},
+		{
+			name: "staleness shorter than refresh interval",
+			modify: func(cfg *Config) {
+				cfg.RefreshInterval = 5 * time.Minute
+				cfg.MaxStaleness = time.Minute
+			},
+			errors: []string{"max_staleness"},
+		},
+		{
+			name:   "staleness disabled",
+			modify: func(cfg *Config) { cfg.MaxStaleness = 0 },
+		},
+		{
+			name:   "unknown snapshot store",
+			modify: func(cfg *Config) { cfg.SnapshotStore.Type = "redis" },
+			errors: []string{"snapshot_store.type"},
+		},
+		{
+			name: "api settings",
+			modify: func(cfg *Config) {
+				cfg.API.MaxPages = -1
+				cfg.API.Timeout = 0
+				cfg.API.RateLimitReserve = -1
+				cfg.API.Concurrency = 0
+			},
+			errors: []string{"api.max_pages", "api.timeout", "api.rate_limit_reserve", "api.concurrency"},
+		},
+		{
+			name: "issues without windows",
+			modify: func(cfg *Config) {
+				cfg.Metrics.Issues1H = false
+			},
+			errors: []string{"metrics.issues"},
+		},
+		{
+			name: "invalid stats periods",
+			modify: func(cfg *Config) {
+				cfg.Metrics.SessionsWindow = "1 day"
+				cfg.Metrics.IssueAlertWindows = List{"24h", "0d"}
+			},
+			errors: []string{"metrics.sessions_window", "metrics.issue_alert_windows"},
+		},
+		{
+			name: "limits",
+			modify: func(cfg *Config) {
+				cfg.Metrics.TransactionsLimit = 0
+				cfg.Metrics.ReleasesLimit = -1
+			},
+			errors: []string{"metrics.transactions_limit", "metrics.releases_limit"},
+		},
+		{
+			name:   "no instances",
+			modify: func(cfg *Config) { cfg.Instances = nil },
+			errors: []string{"no Sentry instance"},
+		},
+		{
+			name: "instance errors",
+			modify: func(cfg *Config) {
+				cfg.Instances = append(cfg.Instances,
+					Instance{Name: "default", AuthToken: "token", OrgSlugs: List{"*"}},
+					Instance{Name: "self-hosted", APIBaseURL: "sentry.example.com"},
+					Instance{})
+			},
+			errors: []string{
+				"duplicate Sentry instance default",
+				"instance self-hosted: api_base_url",
+				"SENTRY_INSTANCE_SELF_HOSTED_AUTH_TOKEN",
+				"SENTRY_INSTANCE_SELF_HOSTED_ORG_SLUG",
+				"instances[3].name",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := validConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("Validate() = %v, want nil", err)
+				}
+				return
+			}
+			errs, ok := err.(Errors)
+			if !ok {
+				t.Fatalf("Validate() = %v, want Errors", err)
+			}
+			if len(errs) != len(tt.errors) {
+				t.Errorf("Validate() returned %d errors, want %d: %v", len(errs), len(tt.errors), err)
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Validate() = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateDefaults(t *testing.T) {
+	cfg := validConfig()
+	cfg.SnapshotStore.Type = StoreBolt
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Validate() = %v", err)
+	}
+	if cfg.Instances[0].APIBaseURL != DefaultAPIBaseURL {
+		t.Errorf("api_base_url = %q, want %q", cfg.Instances[0].APIBaseURL, DefaultAPIBaseURL)
+	}
+	if cfg.SnapshotStore.Path != DefaultBoltPath {
+		t.Errorf("snapshot_store.path = %q, want %q", cfg.SnapshotStore.Path, DefaultBoltPath)
+	}
+}
+
+// writeConfig 将 YAML 写入临时目录下的配置文件并返回路径
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	file := `
+exporter_port: "9000"
+refresh_interval: 5m
+api:
+  max_pages: 8
+metrics:
+  issues_24h: true
+  outcome_categories: error, transaction
+instances:
+  - name: default
+    auth_token: file-token
+    org_slugs: [acme]
+  - name: self-hosted
+    api_base_url: https://sentry.example.com/api/0/
+    auth_token: self-hosted-token
+    org_slugs: "*"
+`
+	tests := []struct {
+		name  string
+		file  string
+		env   map[string]string
+		check func(t *testing.T, cfg *Config)
+	}{
+		{
+			name: "file only",
+			file: file,
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.ExporterPort != "9000" || cfg.RefreshInterval != 5*time.Minute || cfg.API.MaxPages != 8 {
+					t.Errorf("port, refresh interval, max pages = %s, %s, %d", cfg.ExporterPort, cfg.RefreshInterval, cfg.API.MaxPages)
+				}
+				if !cfg.Metrics.Issues1H || !cfg.Metrics.Issues24H {
+					t.Errorf("issues_1h, issues_24h = %v, %v, want both enabled", cfg.Metrics.Issues1H, cfg.Metrics.Issues24H)
+				}
+				if want := (List{"error", "transaction"}); !reflect.DeepEqual(cfg.Metrics.OutcomeCategories, want) {
+					t.Errorf("outcome_categories = %v, want %v", cfg.Metrics.OutcomeCategories, want)
+				}
+				if want := (List{"*"}); !reflect.DeepEqual(cfg.Instances[1].OrgSlugs, want) {
+					t.Errorf("self-hosted org_slugs = %v, want %v", cfg.Instances[1].OrgSlugs, want)
+				}
+			},
+		},
+		{
+			name: "env overrides file",
+			file: file,
+			env: map[string]string{
+				"EXPORTER_PORT":                             "9100",
+				"SENTRY_API_MAX_PAGES":                      "2",
+				"SENTRY_ISSUES_24H":                         "false",
+				"SENTRY_AUTH_TOKEN":                         "env-token",
+				"SENTRY_INSTANCE_SELF_HOSTED_ORG_SLUG":      "ops, infra",
+				"SENTRY_INSTANCE_SELF_HOSTED_PROJECTS":      `api-*,re:^web-\d{1\,3}$`,
+				"SENTRY_INSTANCE_SELF_HOSTED_AUTH_TOKEN":    "",
+				"SENTRY_INSTANCE_SELF_HOSTED_API_BASE_URL":  "",
+				"SENTRY_EXPORTER_INCLUDE_INACTIVE_PROJECTS": "true",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if cfg.ExporterPort != "9100" || cfg.API.MaxPages != 2 || cfg.Metrics.Issues24H {
+					t.Errorf("port, max pages, issues_24h = %s, %d, %v", cfg.ExporterPort, cfg.API.MaxPages, cfg.Metrics.Issues24H)
+				}
+				def, selfHosted := cfg.Instances[0], cfg.Instances[1]
+				if def.AuthToken != "env-token" || !def.IncludeInactiveProjects {
+					t.Errorf("default instance = %+v", def)
+				}
+				// 空的环境变量不覆盖配置文件
+				if selfHosted.AuthToken != "self-hosted-token" || selfHosted.APIBaseURL != "https://sentry.example.com/api/0/" {
+					t.Errorf("self-hosted auth_token, api_base_url = %q, %q", selfHosted.AuthToken, selfHosted.APIBaseURL)
+				}
+				if want := (List{"ops", "infra"}); !reflect.DeepEqual(selfHosted.OrgSlugs, want) {
+					t.Errorf("self-hosted org_slugs = %v, want %v", selfHosted.OrgSlugs, want)
+				}
+				if want := (List{"api-*", `re:^web-\d{1,3}$`}); !reflect.DeepEqual(selfHosted.Projects, want) {
+					t.Errorf("self-hosted projects = %v, want %v", selfHosted.Projects, want)
+				}
+			},
+		},
+		{
+			name: "env only",
+			env: map[string]string{
+				"SENTRY_AUTH_TOKEN":               "env-token",
+				"SENTRY_EXPORTER_ORG_SLUG":        "acme",
+				"SENTRY_INSTANCES":                "eu",
+				"SENTRY_INSTANCE_EU_AUTH_TOKEN":   "eu-token",
+				"SENTRY_INSTANCE_EU_ORG_SLUG":     "acme-eu",
+				"SENTRY_INSTANCE_EU_API_BASE_URL": "https://de.sentry.io/api/0/",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				// 设置了 SENTRY_INSTANCES 时不再隐式创建 default 实例
+				if len(cfg.Instances) != 1 || cfg.Instances[0].Name != "eu" {
+					t.Fatalf("instances = %+v, want only eu", cfg.Instances)
+				}
+				eu := cfg.Instances[0]
+				if eu.AuthToken != "eu-token" || eu.APIBaseURL != "https://de.sentry.io/api/0/" || !reflect.DeepEqual(eu.OrgSlugs, List{"acme-eu"}) {
+					t.Errorf("eu instance = %+v", eu)
+				}
+			},
+		},
+		{
+			name: "default instance from env",
+			env: map[string]string{
+				"SENTRY_AUTH_TOKEN":                "env-token",
+				"SENTRY_EXPORTER_ORG_SLUG":         "acme",
+				"SENTRY_SCRAPE_TIMEOUT":            "90s",
+				"SENTRY_ISSUES_FULL_SYNC_INTERVAL": "0",
+			},
+			check: func(t *testing.T, cfg *Config) {
+				if len(cfg.Instances) != 1 || cfg.Instances[0].Name != DefaultInstanceName || cfg.Instances[0].APIBaseURL != DefaultAPIBaseURL {
+					t.Fatalf("instances = %+v, want the default instance", cfg.Instances)
+				}
+				if cfg.RefreshTimeout != 90*time.Second || cfg.Metrics.IssuesFullSyncInterval != 0 {
+					t.Errorf("refresh timeout, issues full sync interval = %s, %s", cfg.RefreshTimeout, cfg.Metrics.IssuesFullSyncInterval)
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			path := ""
+			if tt.file != "" {
+				path = writeConfig(t, tt.file)
+			}
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Load() = %v", err)
+			}
+			tt.check(t, cfg)
+		})
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name   string
+		file   string
+		env    map[string]string
+		errors []string
+	}{
+		{
+			name:   "unknown field",
+			file:   "exporter_port: \"9000\"\nrefresh_intervall: 5m\n",
+			errors: []string{"refresh_intervall"},
+		},
+		{
+			name: "wrong types and invalid env values reported together",
+			file: "api:\n  max_pages: many\ninstances:\n  - name: default\n    auth_token: x\n    org_slugs: [acme]\n",
+			env: map[string]string{
+				"SENTRY_API_TIMEOUT":    "30",
+				"SENTRY_ISSUE_METRICS":  "yes please",
+				"SENTRY_RELEASES_LIMIT": "five",
+				"SENTRY_SNAPSHOT_STORE": "redis",
+			},
+			errors: []string{"many", "SENTRY_API_TIMEOUT", "SENTRY_ISSUE_METRICS", "SENTRY_RELEASES_LIMIT", "snapshot_store.type"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+			_, err := Load(writeConfig(t, tt.file))
+			if err == nil {
+				t.Fatalf("Load() = nil, want error")
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("Load() = %v, want an error containing %q", err, want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitList(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"acme", []string{"acme"}},
+		{" api-* , web ,, ", []string{"api-*", "web"}},
+		{`api-*,re:^web-\d{1\,3}$`, []string{"api-*", `re:^web-\d{1,3}$`}},
+		{`re:^a\.b$,c`, []string{`re:^a\.b$`, "c"}},
+	}
+	for _, tt := range tests {
+		if got := splitList(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("splitList(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package config
+
+import (
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// applyEnv 用已设置 (非空) 的环境变量覆盖配置文件中的值, 无法解析的值记录到 errs
+func (cfg *Config) applyEnv(errs *Errors) {
+	envString("EXPORTER_PORT", &cfg.ExporterPort)
+	envDuration(errs, "SENTRY_REFRESH_INTERVAL", &cfg.RefreshInterval)
+	// SENTRY_SCRAPE_TIMEOUT 为旧版本的变量名, 数据改为后台刷新后兼容为刷新时限
+	envDuration(errs, "SENTRY_SCRAPE_TIMEOUT", &cfg.RefreshTimeout)
+	envDuration(errs, "SENTRY_REFRESH_TIMEOUT", &cfg.RefreshTimeout)
+	envDuration(errs, "SENTRY_MAX_STALENESS", &cfg.MaxStaleness)
+	envString("SENTRY_SNAPSHOT_STORE", &cfg.SnapshotStore.Type)
+	envString("SENTRY_SNAPSHOT_STORE_PATH", &cfg.SnapshotStore.Path)
+
+	envInt(errs, "SENTRY_API_MAX_PAGES", &cfg.API.MaxPages)
+	envDuration(errs, "SENTRY_API_TIMEOUT", &cfg.API.Timeout)
+	envInt(errs, "SENTRY_API_RATE_LIMIT_RESERVE", &cfg.API.RateLimitReserve)
+	envInt(errs, "SENTRY_API_CONCURRENCY", &cfg.API.Concurrency)
+
+	m := &cfg.Metrics
+	envBool(errs, "SENTRY_ISSUE_METRICS", &m.Issues)
+	envBool(errs, "SENTRY_EVENTS_METRICS", &m.Events)
+	envBool(errs, "SENTRY_RATE_LIMIT_METRICS", &m.RateLimit)
+	envBool(errs, "SENTRY_ISSUES_1H", &m.Issues1H)
+	envBool(errs, "SENTRY_ISSUES_24H", &m.Issues24H)
+	envBool(errs, "SENTRY_ISSUES_14D", &m.Issues14D)
+	envBool(errs, "SENTRY_ORG_ISSUES", &m.OrgIssues)
+	envDuration(errs, "SENTRY_ISSUES_FULL_SYNC_INTERVAL", &m.IssuesFullSyncInterval)
+	envBool(errs, "SENTRY_SESSION_METRICS", &m.Sessions)
+	envString("SENTRY_SESSIONS_WINDOW", &m.SessionsWindow)
+	envBool(errs, "SENTRY_OUTCOME_METRICS", &m.Outcomes)
+	envString("SENTRY_OUTCOMES_WINDOW", &m.OutcomesWindow)
+	envList("SENTRY_OUTCOME_CATEGORIES", &m.OutcomeCategories)
+	envBool(errs, "SENTRY_TRANSACTION_METRICS", &m.Transactions)
+	envString("SENTRY_TRANSACTIONS_WINDOW", &m.TransactionsWindow)
+	envList("SENTRY_TRANSACTION_FIELDS", &m.TransactionFields)
+	envInt(errs, "SENTRY_TRANSACTIONS_LIMIT", &m.TransactionsLimit)
+	envBool(errs, "SENTRY_RELEASE_METRICS", &m.Releases)
+	envString("SENTRY_RELEASES_WINDOW", &m.ReleasesWindow)
+	envInt(errs, "SENTRY_RELEASES_LIMIT", &m.ReleasesLimit)
+	envBool(errs, "SENTRY_MONITOR_METRICS", &m.Monitors)
+	envBool(errs, "SENTRY_ALERT_METRICS", &m.Alerts)
+	envString("SENTRY_INCIDENTS_WINDOW", &m.IncidentsWindow)
+	envBool(errs, "SENTRY_ISSUE_ALERT_METRICS", &m.IssueAlerts)
+	envList("SENTRY_ISSUE_ALERT_WINDOWS", &m.IssueAlertWindows)
+
+	cfg.applyInstancesEnv(errs)
+}
+
+// applyInstancesEnv 合并环境变量中的 Sentry 实例配置
+// SENTRY_INSTANCES 中的实例不存在时追加; 所有实例都可以用 SENTRY_INSTANCE_<NAME>_* 覆盖,
+// 名为 default 的实例还可以用 SENTRY_API_BASE_URL、SENTRY_AUTH_TOKEN 等变量覆盖;
+// 既没有配置文件中的实例也没有 SENTRY_INSTANCES 时使用 default 实例
+func (cfg *Config) applyInstancesEnv(errs *Errors) {
+	for _, name := range splitList(os.Getenv("SENTRY_INSTANCES")) {
+		if cfg.instance(name) == nil {
+			cfg.Instances = append(cfg.Instances, Instance{Name: name})
+		}
+	}
+	if len(cfg.Instances) == 0 {
+		cfg.Instances = append(cfg.Instances, Instance{Name: DefaultInstanceName})
+	}
+
+	for i := range cfg.Instances {
+		instance := &cfg.Instances[i]
+		if instance.Name == DefaultInstanceName {
+			instance.applyEnv(errs, "SENTRY_API_BASE_URL", "SENTRY_AUTH_TOKEN", "SENTRY_EXPORTER_ORG_SLUG", "SENTRY_EXPORTER_")
+		}
+		prefix := "SENTRY_INSTANCE_" + envName(instance.Name) + "_"
+		instance.applyEnv(errs, prefix+"API_BASE_URL", prefix+"AUTH_TOKEN", prefix+"ORG_SLUG", prefix)
+	}
+}
+
+// applyEnv 用环境变量覆盖实例配置, projectPrefix 为项目过滤变量的前缀, 如 SENTRY_EXPORTER_PROJECTS 的 SENTRY_EXPORTER_
+func (instance *Instance) applyEnv(errs *Errors, baseURLKey, tokenKey, orgKey, projectPrefix string) {
+	envString(baseURLKey, &instance.APIBaseURL)
+	envString(tokenKey, &instance.AuthToken)
+	envList(orgKey, &instance.OrgSlugs)
+	envList(projectPrefix+"PROJECTS", &instance.Projects)
+	envList(projectPrefix+"PROJECTS_EXCLUDE", &instance.ExcludeProjects)
+	envList(projectPrefix+"PROJECT_PLATFORMS", &instance.ProjectPlatforms)
+	envList(projectPrefix+"PROJECT_TEAMS", &instance.ProjectTeams)
+	envBool(errs, projectPrefix+"INCLUDE_INACTIVE_PROJECTS", &instance.IncludeInactiveProjects)
+}
+
+// instance 按名称查找实例配置
+func (cfg *Config) instance(name string) *Instance {
+	for i := range cfg.Instances {
+		if cfg.Instances[i].Name == name {
+			return &cfg.Instances[i]
+		}
+	}
+	return nil
+}
+
+// envName 将实例名称转换为环境变量名的一部分, 例如 self-hosted -> SELF_HOSTED
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, strings.ToUpper(name))
+}
+
+// envString 环境变量非空时覆盖 dst
+func envString(key string, dst *string) {
+	if value := os.Getenv(key); value != "" {
+		*dst = value
+	}
+}
+
+// envList 环境变量非空时覆盖 dst, 值为逗号分隔的列表
+func envList(key string, dst *List) {
+	if value := os.Getenv(key); value != "" {
+		*dst = splitList(value)
+	}
+}
+
+// envBool 环境变量非空时覆盖 dst, 取值为 strconv.ParseBool 支持的 true/false/1/0 等
+func envBool(errs *Errors, key string, dst *bool) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := strconv.ParseBool(value)
+	if err != nil {
+		errs.add("%s: %q is not a valid boolean, use true or false", key, value)
+		return
+	}
+	*dst = v
+}
+
+// envInt 环境变量非空时覆盖 dst
+func envInt(errs *Errors, key string, dst *int) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		errs.add("%s: %q is not a valid integer", key, value)
+		return
+	}
+	*dst = v
+}
+
+// envDuration 环境变量非空时覆盖 dst, 格式为 time.ParseDuration 支持的 30s、4m 等
+func envDuration(errs *Errors, key string, dst *time.Duration) {
+	value := os.Getenv(key)
+	if value == "" {
+		return
+	}
+	v, err := time.ParseDuration(value)
+	if err != nil {
+		errs.add("%s: %q is not a valid duration, e.g. 30s, 4m", key, value)
+		return
+	}
+	*dst = v
+}
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"flag"
+	"os"
+)
+
+// envFlag 与环境变量一一对应的命令行参数
+type envFlag struct {
+	name string
+	env  string
+	// isBool 为 true 时可以省略参数值, 如 -metrics.sessions 等同于 -metrics.sessions=true
+	isBool bool
+	usage  string
+}
+
+// envFlags 命令行参数列表, 命令行参数优先于环境变量, 环境变量优先于配置文件
+var envFlags = []envFlag{
+	{"config.file", FileEnv, false, "Path to the YAML configuration file"},
+	{"web.port", "EXPORTER_PORT", false, "Port to expose metrics on"},
+	{"sentry.api-base-url", "SENTRY_API_BASE_URL", false, "Sentry API base URL of the default instance, e.g. https://sentry.io/api/0/"},
+	{"sentry.auth-token", "SENTRY_AUTH_TOKEN", false, "Sentry auth token of the default instance"},
+	{"sentry.org-slug", "SENTRY_EXPORTER_ORG_SLUG", false, "Comma separated organization slugs of the default instance, * for all organizations"},
+	{"sentry.projects", "SENTRY_EXPORTER_PROJECTS", false, "Comma separated project include patterns of the default instance"},
+	{"sentry.projects-exclude", "SENTRY_EXPORTER_PROJECTS_EXCLUDE", false, "Comma separated project exclude patterns of the default instance"},
+	{"sentry.project-platforms", "SENTRY_EXPORTER_PROJECT_PLATFORMS", false, "Comma separated project platform patterns of the default instance"},
+	{"sentry.project-teams", "SENTRY_EXPORTER_PROJECT_TEAMS", false, "Comma separated project team patterns of the default instance"},
+	{"sentry.include-inactive-projects", "SENTRY_EXPORTER_INCLUDE_INACTIVE_PROJECTS", true, "Keep projects whose status is not active"},
+	{"sentry.instances", "SENTRY_INSTANCES", false, "Comma separated names of Sentry instances, configured by SENTRY_INSTANCE_<NAME>_* env vars"},
+	{"snapshot.max-staleness", "SENTRY_MAX_STALENESS", false, "How long stale Sentry data is served while refreshes fail, 0 for no limit"},
+	{"snapshot.store", "SENTRY_SNAPSHOT_STORE", false, "Where refreshed Sentry data is kept: memory, file or bolt"},
+	{"snapshot.store-path", "SENTRY_SNAPSHOT_STORE_PATH", false, "Directory of snapshot files (file) or path of the database (bolt)"},
+	{"sentry.api-max-pages", "SENTRY_API_MAX_PAGES", false, "Maximum number of pages followed by a single list call"},
+	{"sentry.api-timeout", "SENTRY_API_TIMEOUT", false, "Total time a single Sentry API call may take, including retries and rate limit waits"},
+	{"sentry.api-rate-limit-reserve", "SENTRY_API_RATE_LIMIT_RESERVE", false, "Number of rate limit requests left for other clients"},
+	{"sentry.api-concurrency", "SENTRY_API_CONCURRENCY", false, "Number of concurrent Sentry API requests per organization during a refresh"},
+	{"sentry.refresh-interval", "SENTRY_REFRESH_INTERVAL", false, "Interval between background refreshes of Sentry data"},
+	{"sentry.refresh-timeout", "SENTRY_REFRESH_TIMEOUT", false, "Total time a background refresh may spend calling the Sentry API"},
+	{"metrics.issues", "SENTRY_ISSUE_METRICS", true, "Collect issue metrics"},
+	{"metrics.events", "SENTRY_EVENTS_METRICS", true, "Collect event metrics"},
+	{"metrics.rate-limit", "SENTRY_RATE_LIMIT_METRICS", true, "Collect rate limit metrics"},
+	{"metrics.issues-1h", "SENTRY_ISSUES_1H", true, "Collect issues seen in the last hour"},
+	{"metrics.issues-24h", "SENTRY_ISSUES_24H", true, "Collect issues seen in the last 24 hours"},
+	{"metrics.issues-14d", "SENTRY_ISSUES_14D", true, "Collect issues seen in the last 14 days"},
+	{"metrics.org-issues", "SENTRY_ORG_ISSUES", true, "Fetch issues through the organization issues endpoint"},
+	{"metrics.issues-full-sync-interval", "SENTRY_ISSUES_FULL_SYNC_INTERVAL", false, "Interval between full issue syncs, issues are synced incrementally in between, 0 to always sync all"},
+	{"metrics.sessions", "SENTRY_SESSION_METRICS", true, "Collect session (release health) metrics"},
+	{"metrics.sessions-window", "SENTRY_SESSIONS_WINDOW", false, "Stats period of session metrics"},
+	{"metrics.outcomes", "SENTRY_OUTCOME_METRICS", true, "Collect outcome metrics"},
+	{"metrics.outcomes-window", "SENTRY_OUTCOMES_WINDOW", false, "Stats period of outcome metrics"},
+	{"metrics.outcome-categories", "SENTRY_OUTCOME_CATEGORIES", false, "Comma separated outcome categories"},
+	{"metrics.transactions", "SENTRY_TRANSACTION_METRICS", true, "Collect transaction (performance) metrics"},
+	{"metrics.transactions-window", "SENTRY_TRANSACTIONS_WINDOW", false, "Stats period of transaction metrics"},
+	{"metrics.transaction-fields", "SENTRY_TRANSACTION_FIELDS", false, "Comma separated Discover aggregate fields"},
+	{"metrics.transactions-limit", "SENTRY_TRANSACTIONS_LIMIT", false, "Number of transactions per project"},
+	{"metrics.releases", "SENTRY_RELEASE_METRICS", true, "Collect release metrics"},
+	{"metrics.releases-window", "SENTRY_RELEASES_WINDOW", false, "Stats period of release health"},
+	{"metrics.releases-limit", "SENTRY_RELEASES_LIMIT", false, "Number of releases per project and environment"},
+	{"metrics.monitors", "SENTRY_MONITOR_METRICS", true, "Collect cron monitor metrics"},
+	{"metrics.alerts", "SENTRY_ALERT_METRICS", true, "Collect metric alert rules and incidents"},
+	{"metrics.incidents-window", "SENTRY_INCIDENTS_WINDOW", false, "Stats period of metric alert incidents"},
+	{"metrics.issue-alerts", "SENTRY_ISSUE_ALERT_METRICS", true, "Collect issue alert rule fire history"},
+	{"metrics.issue-alert-windows", "SENTRY_ISSUE_ALERT_WINDOWS", false, "Comma separated windows of issue alert rule fire history"},
+}
+
+// RegisterFlags 注册与环境变量对应的命令行参数, 解析时将参数值写入对应的环境变量,
+// 因此重新加载配置时命令行参数依然生效
+func RegisterFlags(fs *flag.FlagSet) {
+	for _, f := range envFlags {
+		fs.Var(&envValue{env: f.env, isBool: f.isBool}, f.name, f.usage+" (env "+f.env+")")
+	}
+}
+
+// envValue 实现 flag.Value, 值保存在环境变量中
+type envValue struct {
+	env    string
+	isBool bool
+}
+
+// String 返回空字符串, 环境变量中可能包含 token, 不在帮助信息中作为默认值显示
+func (v *envValue) String() string {
+	return ""
+}
+
+func (v *envValue) Set(value string) error {
+	return os.Setenv(v.env, value)
+}
+
+func (v *envValue) IsBoolFlag() bool {
+	return v.isBool
+}
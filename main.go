@@ -1,53 +1,97 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"log"
 	"net/http"
 	"os"
-	"sentry-exporter/collector"
+	"os/signal"
 	"sentry-exporter/config"
-	"sentry-exporter/sentry"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
 )
 
+// command 子命令, path 为配置文件路径, cfg 为加载并校验后的配置
+type command struct {
+	usage string
+	run   func(ctx context.Context, path string, cfg *config.Config) error
+}
+
+// commands 支持的子命令, 未指定子命令时为 serve
+var commands = map[string]command{
+	"serve":        {"Serve metrics over HTTP (default)", runServe},
+	"check-config": {"Validate the configuration and the auth tokens against the Sentry API", runCheckConfig},
+	"once":         {"Collect metrics once and print them to stdout", runOnce},
+	"discover":     {"List organizations, projects and environments visible to the auth tokens", runDiscover},
+}
+
 func main() {
-	sentryAPI := sentry.NewSentryAPI(config.SentryAPIBaseURL, config.SentryAuthToken)
-	resp, err := sentryAPI.Get("organizations/")
-	if err != nil {
-		log.Fatalf("Failed to get organizations: %v", err)
+	name := "serve"
+	args := os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
 	}
-	defer resp.Body.Close()
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", name)
+		printUsage(nil)
+		os.Exit(2)
+	}
+
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	config.RegisterFlags(fs)
+	fs.Usage = func() { printUsage(fs) }
+	_ = fs.Parse(args)
 
-	//// 初始化 Sentry Collector
-	//colle := collector.NewSentryCollector(sentryAPI, "sentry", []string{}, []bool{true, true, true, false, true, false})
-	//// 获取 Sentry 数据测试
-	//data := colle.FetchSentryData()
-	//if data == nil {
-	//	log.Fatalf("Failed to build Sentry data")
-	//}
-	//// 打印获取到的数据
-	//fmt.Printf("Sentry Data:\n%v\n", data)
+	// 收到退出信号后取消 ctx, 中断进行中的 Sentry 请求
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// 初始化 Sentry Collector
-	var projects []string
-	if config.SentryExporterProjects == "" {
-		projects = []string{}
-	} else {
-		projects = []string{config.SentryExporterProjects}
+	path := os.Getenv(config.FileEnv)
+	cfg, err := config.Load(path)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
 	}
+	if err := cmd.run(ctx, path, cfg); err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+}
 
-	colle1 := collector.NewSentryCollector(sentryAPI, config.SentryExporterOrgSlug, projects,
-		[]bool{config.SentryIssueMetrics,
-			config.SentryEventsMetrics,
-			config.SentryRateLimitMetrics,
-			config.SentryIssues1H,
-			config.SentryIssues24H,
-			config.SentryIssues14D})
+// printUsage 打印子命令列表, fs 不为 nil 时同时打印命令行参数
+func printUsage(fs *flag.FlagSet) {
+	fmt.Fprintf(os.Stderr, "Usage: %s [command] [flags]\n\nCommands:\n", os.Args[0])
+	var names []string
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].usage)
+	}
+	if fs != nil {
+		fmt.Fprintf(os.Stderr, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+}
+
+// runServe 通过 HTTP 暴露指标, 直到收到退出信号
+func runServe(ctx context.Context, path string, cfg *config.Config) error {
+	// 每个 Sentry 实例使用独立的 SentryAPI 和 SentryCollector, 指标带有 instance 标签
+	// 收到 SIGHUP 或配置文件变化时重新加载配置并重建 collectors, HTTP 服务器不受影响
+	exp := newExporter(ctx)
+	if err := exp.apply(cfg); err != nil {
+		return fmt.Errorf("failed to build collectors: %v", err)
+	}
+	go exp.watch(ctx, path)
 
-	// 注册收集器
-	prometheus.MustRegister(colle1)
 	router := gin.Default()
 	// Home endpoint
 	router.GET("/", func(c *gin.Context) {
@@ -58,15 +102,28 @@ func main() {
 		c.JSON(http.StatusOK, gin.H{"status": "healthy"})
 	})
 	// Metrics endpoint
-	router.GET("/metrics", func(c *gin.Context) {
-		h := promhttp.Handler()
-		h.ServeHTTP(c.Writer, c.Request)
-	})
+	metricsHandler := promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, promhttp.HandlerFor(exp.gatherer(), promhttp.HandlerOpts{}))
+	router.GET("/metrics", gin.WrapH(metricsHandler))
 	// 启动 HTTP 服务器
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	log.Printf("Starting server on port %s\n", cfg.ExporterPort)
+	srv := &http.Server{
+		Addr:    "0.0.0.0:" + cfg.ExporterPort,
+		Handler: router,
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Failed to start server: %v", err)
+		}
+	}()
+
+	// 等待退出信号, 优雅关闭 HTTP 服务器
+	<-ctx.Done()
+	log.Printf("Shutting down server...\n")
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown failed: %v\n", err)
 	}
-	log.Printf("Starting server on port %s\n", port)
-	log.Fatal(router.Run("0.0.0.0:" + config.EXPORTER_PORT))
+	exp.close()
+	return nil
 }
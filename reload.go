@@ -0,0 +1,324 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"log"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sentry-exporter/collector"
+	"sentry-exporter/config"
+	"sentry-exporter/sentry"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+)
+
+// configWatchInterval 检查配置文件是否变化的间隔
+const configWatchInterval = 10 * time.Second
+
+var (
+	configLastReloadSuccessful = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sentry_exporter_config_last_reload_successful",
+		Help: "Whether the last configuration reload attempt was successful",
+	})
+	configLastReloadSuccessTimestamp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Name: "sentry_exporter_config_last_reload_success_timestamp_seconds",
+		Help: "Timestamp of the last successful configuration reload",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(configLastReloadSuccessful, configLastReloadSuccessTimestamp)
+}
+
+// exporter 持有按当前配置构建的 collectors, 重新加载配置时整体替换
+type exporter struct {
+	// ctx 在进程退出时取消
+	ctx context.Context
+	// mu 保证同一时间只有一次重新加载
+	mu sync.Mutex
+	// registry 当前配置的 collectors 所在的 registry, /metrics 每次请求时读取
+	registry atomic.Pointer[prometheus.Registry]
+	// cfg 当前生效的配置
+	cfg *config.Config
+	// cancel 停止当前 collectors 的后台刷新, 并取消进行中的 Sentry 请求, running 在所有后台刷新退出后归零
+	cancel  context.CancelFunc
+	running *sync.WaitGroup
+	// stores 当前 collectors 使用的 snapshot 存储
+	stores *snapshotStores
+}
+
+// newExporter 创建 exporter, 需要调用 apply 加载配置后才会产生 Sentry 指标
+func newExporter(ctx context.Context) *exporter {
+	e := &exporter{ctx: ctx}
+	e.registry.Store(prometheus.NewRegistry())
+	return e
+}
+
+// gatherer 返回进程指标和当前 collectors 的指标, 每次 Gather 时读取当前的 registry, 重新加载后无需重建
+func (e *exporter) gatherer() prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return prometheus.Gatherers{prometheus.DefaultGatherer, e.registry.Load()}.Gather()
+	})
+}
+
+// apply 按配置构建所有实例的 collectors, 全部构建成功后才替换当前的 collectors 并启动后台刷新
+func (e *exporter) apply(cfg *config.Config) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	stores := e.stores
+	if stores == nil || stores.cfg != cfg.SnapshotStore {
+		var err error
+		if stores, err = openSnapshotStores(cfg.SnapshotStore); err != nil {
+			return err
+		}
+	}
+	registry, collectors, err := buildCollectors(cfg, stores)
+	if err != nil {
+		if stores != e.stores {
+			stores.Close()
+		}
+		return err
+	}
+
+	if e.cfg != nil && e.cfg.ExporterPort != cfg.ExporterPort {
+		log.Printf("config: exporter port changed from %s to %s, restart required to take effect\n", e.cfg.ExporterPort, cfg.ExporterPort)
+	}
+	// 旧的 collectors 完全退出 (包括最后一次保存 snapshot) 后再从存储加载 snapshot、启动新的 collectors 和关闭旧的存储,
+	// 避免两代 collectors 同时写入同一个存储, 或向已关闭的存储写入; 加载完成后再替换 registry, 期间抓取仍返回旧的指标
+	e.stop()
+	for _, colle := range collectors {
+		colle.LoadSnapshot()
+	}
+	e.registry.Store(registry)
+	ctx, cancel := context.WithCancel(e.ctx)
+	running := &sync.WaitGroup{}
+	for _, colle := range collectors {
+		running.Add(1)
+		go func(colle *collector.SentryCollector) {
+			defer running.Done()
+			colle.Run(ctx)
+		}(colle)
+	}
+	if e.stores != nil && e.stores != stores {
+		if err := e.stores.Close(); err != nil {
+			log.Printf("store: failed to close previous snapshot store: %v\n", err)
+		}
+	}
+	e.cfg = cfg
+	e.cancel = cancel
+	e.running = running
+	e.stores = stores
+	configLastReloadSuccessful.Set(1)
+	configLastReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// stop 停止当前 collectors 的后台刷新并等待其退出, 调用方需持有 e.mu
+func (e *exporter) stop() {
+	if e.cancel == nil {
+		return
+	}
+	e.cancel()
+	e.running.Wait()
+	e.cancel, e.running = nil, nil
+}
+
+// close 停止 collectors 的后台刷新并关闭 snapshot 存储
+func (e *exporter) close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.stop()
+	if e.stores != nil {
+		if err := e.stores.Close(); err != nil {
+			log.Printf("store: failed to close snapshot store: %v\n", err)
+		}
+	}
+}
+
+// reload 重新读取配置文件和环境变量, 失败时保留当前的 collectors
+func (e *exporter) reload(path string) {
+	log.Printf("config: reloading configuration\n")
+	cfg, err := config.Load(path)
+	if err == nil {
+		err = e.apply(cfg)
+	}
+	if err != nil {
+		configLastReloadSuccessful.Set(0)
+		log.Printf("config: failed to reload configuration: %v\n", err)
+		return
+	}
+	log.Printf("config: configuration reloaded\n")
+}
+
+// watch 在收到 SIGHUP 或配置文件修改时间变化时重新加载配置, 直到 ctx 取消
+func (e *exporter) watch(ctx context.Context, path string) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	ticker := time.NewTicker(configWatchInterval)
+	defer ticker.Stop()
+	modTime := fileModTime(path)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			modTime = fileModTime(path)
+			e.reload(path)
+		case <-ticker.C:
+			if path == "" {
+				continue
+			}
+			if t := fileModTime(path); !t.Equal(modTime) {
+				modTime = t
+				e.reload(path)
+			}
+		}
+	}
+}
+
+// fileModTime 返回文件的修改时间, 文件不存在或 path 为空时返回零值
+func fileModTime(path string) time.Time {
+	if path == "" {
+		return time.Time{}
+	}
+	info, err := os.Stat(path)
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+// buildCollectors 为每个 Sentry 实例创建 SentryCollector, 注册到新的 registry 中, 指标带有 instance 标签
+func buildCollectors(cfg *config.Config, stores *snapshotStores) (*prometheus.Registry, []*collector.SentryCollector, error) {
+	registry := prometheus.NewRegistry()
+	var collectors []*collector.SentryCollector
+	for _, instance := range cfg.Instances {
+		colle, err := newInstanceCollector(cfg, instance, stores.store(instance.Name))
+		if err != nil {
+			return nil, nil, err
+		}
+		if err := prometheus.WrapRegistererWith(prometheus.Labels{"instance": instance.Name}, registry).Register(colle); err != nil {
+			return nil, nil, fmt.Errorf("failed to register collector for Sentry instance %s: %v", instance.Name, err)
+		}
+		collectors = append(collectors, colle)
+	}
+	return registry, collectors, nil
+}
+
+// newInstanceCollector 为一个 Sentry 实例创建 SentryCollector, 不访问 Sentry API,
+// 实例是否可用由后台刷新 (或 check-config 子命令) 检查, 不影响其他实例和重新加载配置
+func newInstanceCollector(cfg *config.Config, instance config.Instance, store collector.SnapshotStore) (*collector.SentryCollector, error) {
+	projectFilter, err := collector.NewProjectFilter(instance.Projects, instance.ExcludeProjects,
+		instance.ProjectPlatforms, instance.ProjectTeams, instance.IncludeInactiveProjects)
+	if err != nil {
+		return nil, fmt.Errorf("invalid project filter for Sentry instance %s: %v", instance.Name, err)
+	}
+
+	opts := collectorOptions(cfg)
+	opts.Store = store
+	return collector.NewSentryCollector(newSentryAPI(cfg, instance), instance.OrgSlugs, projectFilter, opts), nil
+}
+
+// newSentryAPI 按实例和 API 配置创建 SentryAPI
+func newSentryAPI(cfg *config.Config, instance config.Instance) *sentry.SentryAPI {
+	sentryAPI := sentry.NewSentryAPI(instance.APIBaseURL, instance.AuthToken)
+	sentryAPI.MaxPages = cfg.API.MaxPages
+	sentryAPI.Client.Timeout = cfg.API.Timeout
+	sentryAPI.Timeout = cfg.API.Timeout
+	sentryAPI.RateLimitReserve = cfg.API.RateLimitReserve
+	return sentryAPI
+}
+
+// collectorOptions 将指标配置转换为 collector.Options
+func collectorOptions(cfg *config.Config) collector.Options {
+	m := cfg.Metrics
+	return collector.Options{
+		IssueMetrics:           m.Issues,
+		EventsMetrics:          m.Events,
+		RateLimitMetrics:       m.RateLimit,
+		Issues1H:               m.Issues1H,
+		Issues24H:              m.Issues24H,
+		Issues14D:              m.Issues14D,
+		OrgIssues:              m.OrgIssues,
+		IssuesFullSyncInterval: m.IssuesFullSyncInterval,
+		SessionMetrics:         m.Sessions,
+		SessionsWindow:         m.SessionsWindow,
+		OutcomeMetrics:         m.Outcomes,
+		OutcomesWindow:         m.OutcomesWindow,
+		OutcomeCategories:      m.OutcomeCategories,
+		TransactionMetrics:     m.Transactions,
+		TransactionsWindow:     m.TransactionsWindow,
+		TransactionFields:      m.TransactionFields,
+		TransactionsLimit:      m.TransactionsLimit,
+		ReleaseMetrics:         m.Releases,
+		ReleasesWindow:         m.ReleasesWindow,
+		ReleasesLimit:          m.ReleasesLimit,
+		MonitorMetrics:         m.Monitors,
+		AlertMetrics:           m.Alerts,
+		IncidentsWindow:        m.IncidentsWindow,
+		IssueAlertMetrics:      m.IssueAlerts,
+		IssueAlertWindows:      m.IssueAlertWindows,
+		RefreshInterval:        cfg.RefreshInterval,
+		RefreshTimeout:         cfg.RefreshTimeout,
+		MaxStaleness:           cfg.MaxStaleness,
+		Concurrency:            cfg.API.Concurrency,
+	}
+}
+
+// snapshotStores 按 snapshot_store 配置为每个实例提供 SnapshotStore,
+// 存储配置不变时重新加载配置复用同一个 snapshotStores, 新的 collectors 可以从中恢复数据
+type snapshotStores struct {
+	cfg config.SnapshotStoreConfig
+	// bolt 打开的 bbolt 数据库, 所有实例共用
+	bolt *collector.BoltDB
+	// memory 实例名称 -> MemoryStore
+	memory map[string]*collector.MemoryStore
+}
+
+// openSnapshotStores 按配置打开 snapshot 存储
+func openSnapshotStores(cfg config.SnapshotStoreConfig) (*snapshotStores, error) {
+	s := &snapshotStores{cfg: cfg, memory: make(map[string]*collector.MemoryStore)}
+	if cfg.Type == config.StoreBolt {
+		db, err := collector.OpenBoltDB(cfg.Path)
+		if err != nil {
+			return nil, err
+		}
+		s.bolt = db
+	}
+	return s, nil
+}
+
+// store 返回实例使用的 SnapshotStore
+func (s *snapshotStores) store(instance string) collector.SnapshotStore {
+	switch s.cfg.Type {
+	case config.StoreBolt:
+		return s.bolt.Store(instance)
+	case config.StoreFile:
+		name := collector.JSONCacheFile
+		if instance != config.DefaultInstanceName {
+			name = collector.InstanceCacheFile(instance)
+		}
+		return collector.NewFileStore(filepath.Join(s.cfg.Path, filepath.Base(name)))
+	}
+	if _, ok := s.memory[instance]; !ok {
+		s.memory[instance] = collector.NewMemoryStore()
+	}
+	return s.memory[instance]
+}
+
+// Close 关闭 bbolt 数据库
+func (s *snapshotStores) Close() error {
+	if s.bolt != nil {
+		return s.bolt.Close()
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Incident 状态, 对应 Sentry 的 IncidentStatus
+const (
+	IncidentStatusOpen     = 1
+	IncidentStatusClosed   = 2
+	IncidentStatusWarning  = 10
+	IncidentStatusCritical = 20
+)
+
+// AlertRuleTrigger 指标告警规则的触发器 (critical / warning)
+type AlertRuleTrigger struct {
+	ID               string   `json:"id"`
+	Label            string   `json:"label"`
+	AlertThreshold   *float64 `json:"alertThreshold"`
+	ResolveThreshold *float64 `json:"resolveThreshold"`
+}
+
+// AlertRule 指标告警规则, TimeWindow 单位为分钟, ThresholdType 0 表示高于阈值告警, 1 表示低于阈值告警
+type AlertRule struct {
+	ID               string             `json:"id"`
+	Name             string             `json:"name"`
+	Dataset          string             `json:"dataset"`
+	Aggregate        string             `json:"aggregate"`
+	Query            string             `json:"query"`
+	Environment      string             `json:"environment"`
+	TimeWindow       float64            `json:"timeWindow"`
+	ThresholdType    int                `json:"thresholdType"`
+	ResolveThreshold *float64           `json:"resolveThreshold"`
+	Projects         []string           `json:"projects"`
+	Triggers         []AlertRuleTrigger `json:"triggers"`
+}
+
+// Incident 指标告警规则触发的事件
+type Incident struct {
+	ID           string     `json:"id"`
+	Identifier   string     `json:"identifier"`
+	Title        string     `json:"title"`
+	Status       int        `json:"status"`
+	DateStarted  time.Time  `json:"dateStarted"`
+	DateDetected time.Time  `json:"dateDetected"`
+	DateClosed   *time.Time `json:"dateClosed"`
+	Projects     []string   `json:"projects"`
+	AlertRule    AlertRule  `json:"alertRule"`
+}
+
+// StatusName 返回事件状态名称: open、warning、critical、resolved, 无法识别的状态返回 unknown
+func (i Incident) StatusName() string {
+	switch i.Status {
+	case IncidentStatusOpen:
+		return "open"
+	case IncidentStatusWarning:
+		return "warning"
+	case IncidentStatusCritical:
+		return "critical"
+	case IncidentStatusClosed:
+		return "resolved"
+	default:
+		return "unknown"
+	}
+}
+
+// AlertRules 获取组织下的指标告警规则
+func (s *SentryAPI) AlertRules(ctx context.Context, orgSlug string) ([]AlertRule, error) {
+	rules := []AlertRule{}
+	err := s.GetAll(ctx, fmt.Sprintf("organizations/%s/alert-rules/", orgSlug), &rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alert rules: %v", err)
+	}
+	return rules, nil
+}
+
+// Incidents 获取组织在 statsPeriod 内的指标告警事件 (包括仍未恢复和已恢复的事件)
+func (s *SentryAPI) Incidents(ctx context.Context, orgSlug string, statsPeriod string) ([]Incident, error) {
+	incidents := []Incident{}
+	err := s.GetAll(ctx, fmt.Sprintf("organizations/%s/incidents/?statsPeriod=%s", orgSlug, statsPeriod), &incidents)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch incidents: %v", err)
+	}
+	return incidents, nil
+}
@@ -0,0 +1,78 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+)
+
+// DiscoverRow Discover 查询结果中的一行, key 为请求的 field (如 transaction、p95(transaction.duration))
+type DiscoverRow map[string]interface{}
+
+// String 返回字符串类型的列, 不存在时返回空字符串
+func (r DiscoverRow) String(field string) string {
+	switch v := r[field].(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// Float 返回数值类型的列, 列不存在或为 null 时 ok 为 false
+func (r DiscoverRow) Float(field string) (float64, bool) {
+	switch v := r[field].(type) {
+	case float64:
+		return v, true
+	case string:
+		f, err := strconv.ParseFloat(v, 64)
+		return f, err == nil
+	default:
+		return 0, false
+	}
+}
+
+// DiscoverResult organizations/{org}/events/ 接口的返回结果
+type DiscoverResult struct {
+	Data []DiscoverRow `json:"data"`
+	Meta struct {
+		Fields map[string]string `json:"fields"`
+	} `json:"meta"`
+}
+
+// Discover 通过 organizations/{org}/events/ 执行 Discover 查询
+// fields 同时包含分组列 (如 transaction) 和聚合列 (如 count()), limit 为返回的最大行数
+func (s *SentryAPI) Discover(ctx context.Context, orgSlug string, projectIDs []string, fields []string, query string, sort string, statsPeriod string, limit int) (*DiscoverResult, error) {
+	discoverURL := fmt.Sprintf("organizations/%s/events/?statsPeriod=%s&per_page=%d", orgSlug, statsPeriod, limit)
+	if len(projectIDs) == 0 {
+		discoverURL = withQueryParam(discoverURL, "project", "-1")
+	}
+	for _, id := range projectIDs {
+		discoverURL = withQueryParam(discoverURL, "project", id)
+	}
+	for _, field := range fields {
+		discoverURL = withQueryParam(discoverURL, "field", field)
+	}
+	if query != "" {
+		discoverURL = withQueryParam(discoverURL, "query", query)
+	}
+	if sort != "" {
+		discoverURL = withQueryParam(discoverURL, "sort", sort)
+	}
+
+	resp, err := s.Get(ctx, discoverURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch discover events: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result DiscoverResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode discover events JSON: %v", err)
+	}
+	return &result, nil
+}
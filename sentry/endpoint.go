@@ -0,0 +1,45 @@
+package sentry
+
+import "strings"
+
+// pathPlaceholders 集合名 -> 其后路径段的占位符, 用于把具体 URL 归一化为接口模板
+var pathPlaceholders = map[string]string{
+	"organizations": "{organization_slug}",
+	"issues":        "{issue_id}",
+	"releases":      "{version}",
+	"monitors":      "{monitor_id_or_slug}",
+	"rules":         "{rule_id}",
+	"alert-rules":   "{alert_rule_id}",
+	"incidents":     "{incident_identifier}",
+	"teams":         "{team_slug}",
+}
+
+// endpointTemplate 将请求路径归一化为接口模板并提取组织 slug
+// 例如: projects/acme/web/issues/?query=... -> projects/{organization_slug}/{project_slug}/issues/, acme
+func endpointTemplate(path string) (endpoint string, org string) {
+	if i := strings.IndexAny(path, "?#"); i >= 0 {
+		path = path[:i]
+	}
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+
+	for i := 0; i < len(segments); i++ {
+		if i == 0 && segments[i] == "projects" && len(segments) > 2 {
+			// projects/{organization_slug}/{project_slug}/...
+			org = segments[1]
+			segments[1] = "{organization_slug}"
+			segments[2] = "{project_slug}"
+			i += 2
+			continue
+		}
+		placeholder, ok := pathPlaceholders[segments[i]]
+		if !ok || i+1 >= len(segments) || segments[i+1] == "" {
+			continue
+		}
+		if segments[i] == "organizations" && org == "" {
+			org = segments[i+1]
+		}
+		segments[i+1] = placeholder
+		i++
+	}
+	return strings.Join(segments, "/") + "/", org
+}
@@ -0,0 +1,29 @@
+package sentry
+
+import "testing"
+
+func TestEndpointTemplate(t *testing.T) {
+	tests := []struct {
+		path     string
+		endpoint string
+		org      string
+	}{
+		{"organizations/", "organizations/", ""},
+		{"organizations/acme/", "organizations/{organization_slug}/", "acme"},
+		{"organizations/acme/projects/?cursor=0:100:0", "organizations/{organization_slug}/projects/", "acme"},
+		{"projects/acme/web/issues/?project=1&sort=date", "projects/{organization_slug}/{project_slug}/issues/", "acme"},
+		{"projects/acme/web/environments/", "projects/{organization_slug}/{project_slug}/environments/", "acme"},
+		{"issues/123/current-release/?environment=prod", "issues/{issue_id}/current-release/", ""},
+		{"organizations/acme/releases/1.0.0/deploys/", "organizations/{organization_slug}/releases/{version}/deploys/", "acme"},
+		{"organizations/acme/monitors/0b9c5c5e-3f2a/checkins/?per_page=1", "organizations/{organization_slug}/monitors/{monitor_id_or_slug}/checkins/", "acme"},
+		{"projects/acme/web/rules/42/group-history/", "projects/{organization_slug}/{project_slug}/rules/{rule_id}/group-history/", "acme"},
+		{"organizations/acme/alert-rules/", "organizations/{organization_slug}/alert-rules/", "acme"},
+		{"organizations/acme/teams/backend/projects/", "organizations/{organization_slug}/teams/{team_slug}/projects/", "acme"},
+	}
+	for _, tt := range tests {
+		endpoint, org := endpointTemplate(tt.path)
+		if endpoint != tt.endpoint || org != tt.org {
+			t.Errorf("endpointTemplate(%q) = %q, %q, want %q, %q", tt.path, endpoint, org, tt.endpoint, tt.org)
+		}
+	}
+}
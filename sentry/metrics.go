@@ -0,0 +1,47 @@
+package sentry
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 访问 Sentry API 的自监控指标, 注册在默认 registry 上, 所有实例共用
+// endpoint 标签为 endpointTemplate 归一化后的接口模板, 不包含组织、项目等具体取值
+var (
+	apiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "sentry_exporter_api_requests_total",
+		Help: "Number of HTTP requests sent to the Sentry API, including retries, by endpoint and status code",
+	}, []string{"endpoint", "status_code"})
+	apiRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "sentry_exporter_api_request_duration_seconds",
+		Help:    "Time until the response headers of a Sentry API request were received, excluding throttling waits",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30},
+	}, []string{"endpoint"})
+	apiRetries = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentry_exporter_api_retries_total",
+		Help: "Number of Sentry API requests retried after a failed attempt",
+	})
+	apiRateLimited = prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "sentry_exporter_api_rate_limited_total",
+		Help: "Number of Sentry API responses with status 429 Too Many Requests",
+	})
+)
+
+func init() {
+	prometheus.MustRegister(apiRequests, apiRequestDuration, apiRetries, apiRateLimited)
+}
+
+// observeRequest 记录一次 HTTP 请求的结果和耗时, 没有收到响应 (网络错误、超时等) 时 status_code 为 "error"
+func observeRequest(endpoint string, statusCode int, duration time.Duration) {
+	status := "error"
+	if statusCode > 0 {
+		status = strconv.Itoa(statusCode)
+	}
+	apiRequests.WithLabelValues(endpoint, status).Inc()
+	apiRequestDuration.WithLabelValues(endpoint).Observe(duration.Seconds())
+	if statusCode == http.StatusTooManyRequests {
+		apiRateLimited.Inc()
+	}
+}
@@ -0,0 +1,71 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// MonitorEnvironment Cron 监控在某个环境下的状态
+type MonitorEnvironment struct {
+	Name              string     `json:"name"`
+	Status            string     `json:"status"`
+	IsMuted           bool       `json:"isMuted"`
+	LastCheckIn       *time.Time `json:"lastCheckIn"`
+	NextCheckIn       *time.Time `json:"nextCheckIn"`
+	NextCheckInLatest *time.Time `json:"nextCheckInLatest"`
+}
+
+// Monitor Sentry Cron 监控
+type Monitor struct {
+	ID           string               `json:"id"`
+	Slug         string               `json:"slug"`
+	Name         string               `json:"name"`
+	Status       string               `json:"status"`
+	IsMuted      bool                 `json:"isMuted"`
+	Project      IssueProject         `json:"project"`
+	Environments []MonitorEnvironment `json:"environments"`
+}
+
+// CheckIn Cron 监控的一次签到, Duration 单位为毫秒
+type CheckIn struct {
+	ID          string    `json:"id"`
+	Status      string    `json:"status"`
+	Environment string    `json:"environment"`
+	Duration    *float64  `json:"duration"`
+	DateCreated time.Time `json:"dateCreated"`
+}
+
+// Monitors 获取组织下的 Cron 监控列表 (包含各环境的状态)
+func (s *SentryAPI) Monitors(ctx context.Context, orgSlug string) ([]Monitor, error) {
+	monitors := []Monitor{}
+	err := s.GetAll(ctx, fmt.Sprintf("organizations/%s/monitors/", orgSlug), &monitors)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch monitors: %v", err)
+	}
+	return monitors, nil
+}
+
+// MonitorCheckIns 获取 Cron 监控在指定环境最近 limit 次签到, monitorIDOrSlug 为监控 ID 或 slug,
+// slug 只在项目内唯一, 组织下有同名监控时需要使用 ID
+func (s *SentryAPI) MonitorCheckIns(ctx context.Context, orgSlug string, monitorIDOrSlug string, environment string, limit int) ([]CheckIn, error) {
+	checkInsURL := fmt.Sprintf("organizations/%s/monitors/%s/checkins/?per_page=%d", orgSlug, url.PathEscape(monitorIDOrSlug), limit)
+	if environment != "" {
+		checkInsURL = withQueryParam(checkInsURL, "environment", environment)
+	}
+
+	resp, err := s.Get(ctx, checkInsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch monitor check-ins: %v", err)
+	}
+	defer resp.Body.Close()
+
+	checkIns := []CheckIn{}
+	err = json.NewDecoder(resp.Body).Decode(&checkIns)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode monitor check-ins JSON: %v", err)
+	}
+	return checkIns, nil
+}
@@ -0,0 +1,60 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// OutcomeGroupBy stats_v2 分组的维度取值, 只有请求中 groupBy 的字段才会有值
+type OutcomeGroupBy struct {
+	Project  int64  `json:"project"`
+	Category string `json:"category"`
+	Outcome  string `json:"outcome"`
+	Reason   string `json:"reason"`
+}
+
+// OutcomeGroup stats_v2 中的一个分组, Totals 的 key 为请求的 field, 如 sum(quantity)
+type OutcomeGroup struct {
+	By     OutcomeGroupBy     `json:"by"`
+	Totals map[string]float64 `json:"totals"`
+}
+
+// OutcomesResult organizations/{org}/stats_v2/ 接口的返回结果
+type OutcomesResult struct {
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Groups []OutcomeGroup `json:"groups"`
+}
+
+// OrgStats 通过 stats_v2 一次性获取组织下所有项目按 项目/类别/结果/原因 分组的事件数
+// projectIDs 为空时查询全部项目, categories 如 error、transaction、attachment
+func (s *SentryAPI) OrgStats(ctx context.Context, orgSlug string, projectIDs []string, categories []string, statsPeriod string, interval string) (*OutcomesResult, error) {
+	statsURL := fmt.Sprintf("organizations/%s/stats_v2/?field=sum(quantity)&statsPeriod=%s&interval=%s", orgSlug, statsPeriod, interval)
+	for _, group := range []string{"project", "category", "outcome", "reason"} {
+		statsURL = withQueryParam(statsURL, "groupBy", group)
+	}
+	if len(projectIDs) == 0 {
+		statsURL = withQueryParam(statsURL, "project", "-1")
+	}
+	for _, id := range projectIDs {
+		statsURL = withQueryParam(statsURL, "project", id)
+	}
+	for _, category := range categories {
+		statsURL = withQueryParam(statsURL, "category", category)
+	}
+
+	resp, err := s.Get(ctx, statsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch organization stats: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result OutcomesResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode organization stats JSON: %v", err)
+	}
+	return &result, nil
+}
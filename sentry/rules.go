@@ -0,0 +1,46 @@
+package sentry
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// IssueAlertRule 项目的问题告警规则, Frequency 为同一问题两次告警的最小间隔 (分钟)
+type IssueAlertRule struct {
+	ID          string   `json:"id"`
+	Name        string   `json:"name"`
+	Status      string   `json:"status"`
+	ActionMatch string   `json:"actionMatch"`
+	Environment string   `json:"environment"`
+	Frequency   int      `json:"frequency"`
+	Projects    []string `json:"projects"`
+}
+
+// RuleGroupHistory 问题告警规则在某个问题上的触发记录
+type RuleGroupHistory struct {
+	Group         Issue     `json:"group"`
+	Count         Count     `json:"count"`
+	LastTriggered time.Time `json:"lastTriggered"`
+	EventID       string    `json:"eventId"`
+}
+
+// ProjectRules 获取项目的问题告警规则
+func (s *SentryAPI) ProjectRules(ctx context.Context, orgSlug string, projectSlug string) ([]IssueAlertRule, error) {
+	rules := []IssueAlertRule{}
+	err := s.GetAll(ctx, fmt.Sprintf("projects/%s/%s/rules/", orgSlug, projectSlug), &rules)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch issue alert rules: %v", err)
+	}
+	return rules, nil
+}
+
+// RuleGroupHistory 获取问题告警规则在 statsPeriod 内按问题汇总的触发记录
+func (s *SentryAPI) RuleGroupHistory(ctx context.Context, orgSlug string, projectSlug string, ruleID string, statsPeriod string) ([]RuleGroupHistory, error) {
+	history := []RuleGroupHistory{}
+	err := s.GetAll(ctx, fmt.Sprintf("projects/%s/%s/rules/%s/group-history/?statsPeriod=%s", orgSlug, projectSlug, ruleID, statsPeriod), &history)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch issue alert rule group history: %v", err)
+	}
+	return history, nil
+}
@@ -1,19 +1,39 @@
 package sentry
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/avast/retry-go"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"strings"
 	"time"
 )
 
+const (
+	// DefaultMaxPages 单次列表调用默认最多跟随的分页数
+	DefaultMaxPages = 50
+	// DefaultTimeout 单次 API 调用 (含重试、限流等待和读取响应体) 的默认总时限
+	DefaultTimeout = 30 * time.Second
+)
+
 type SentryAPI struct {
 	BaseURL   string
 	AuthToken string
 	Client    *http.Client
+	// MaxPages 单次列表调用最多跟随的分页数, <= 0 表示不限制
+	MaxPages int
+	// RateLimitReserve 为共享同一组织 API 配额的其他工具预留的请求数
+	RateLimitReserve int
+	// Timeout 单次 Get 调用的总时限, 包括所有重试、重试间隔、限流等待以及读取响应体, <= 0 表示不限制;
+	// Client.Timeout 只限制单次 HTTP 请求, 每次重试都会重新计时
+	Timeout time.Duration
+
+	throttle *throttler
 }
 
 type Organization struct {
@@ -35,42 +55,82 @@ type Project struct {
 	Name     string `json:"name"`
 	Status   string `json:"status"`
 	Platform string `json:"platform"`
+	Teams    []Team `json:"teams,omitempty"`
 }
 
-//type Event struct {
-//	Timestamp string `json:"timestamp"`
-//	Count     int    `json:"count"`
-//}
-
-type Event []interface{}
+// Team 组织下的团队, Projects 为团队拥有的项目
+type Team struct {
+	ID       string    `json:"id"`
+	Slug     string    `json:"slug"`
+	Name     string    `json:"name"`
+	Projects []Project `json:"projects,omitempty"`
+}
 
 func NewSentryAPI(baseURL, authToken string) *SentryAPI {
 	return &SentryAPI{
 		BaseURL:   baseURL,
 		AuthToken: authToken,
-		Client:    &http.Client{},
+		Client:    &http.Client{Timeout: DefaultTimeout},
+		MaxPages:  DefaultMaxPages,
+		Timeout:   DefaultTimeout,
+		throttle:  newThrottler(),
 	}
 }
 
-// Get 发送请求验证Token
-func (s *SentryAPI) Get(url string) (*http.Response, error) {
+// Get 发送请求验证Token, ctx 取消或超时后会中断进行中的请求及重试等待
+// 整个调用 (包括所有重试和读取响应体) 不超过 Timeout, 调用方关闭响应体后释放计时器
+func (s *SentryAPI) Get(ctx context.Context, url string) (*http.Response, error) {
+	parent := ctx
+	cancel := context.CancelFunc(func() {})
+	if s.Timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, s.Timeout)
+	}
 
 	// fmt.Println(s.BaseURL + url)
-	req, err := http.NewRequest(http.MethodGet, s.BaseURL+url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.BaseURL+url, nil)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
 	req.Header.Set("Authorization", "Bearer "+s.AuthToken)
+	endpoint, org := endpointTemplate(url)
+	key := throttleKey(org, endpoint)
 
 	// 使用闭包传递局部变量 resp 和 err
 	var resp *http.Response
 	var doErr error
+	attempts := 0
 	operation := func() error {
+		if attempts++; attempts > 1 {
+			apiRetries.Inc()
+		}
+		// 按 Sentry 限流响应头节流, 429 后等待到 Retry-After / Reset
+		if err := s.throttle.wait(ctx, key, s.RateLimitReserve); err != nil {
+			return err
+		}
+		// 并发请求数不超过 Sentry 的 ConcurrentLimit
+		if err := s.throttle.acquire(ctx, key); err != nil {
+			return err
+		}
+		start := time.Now()
 		resp, doErr = s.Client.Do(req)
 		if doErr != nil {
+			s.throttle.release(key)
+			// 调用方取消时请求没有真正失败, 不计入指标
+			if parent.Err() == nil {
+				observeRequest(endpoint, 0, time.Since(start))
+			}
 			return doErr
 		}
+		observeRequest(endpoint, resp.StatusCode, time.Since(start))
+		s.throttle.update(key, resp)
+		s.throttle.release(key)
+		if resp.StatusCode == http.StatusTooManyRequests {
+			resp.Body.Close()
+			return errRateLimited{status: resp.Status}
+		}
 		if resp.StatusCode >= 400 {
+			resp.Body.Close()
 			return fmt.Errorf("HTTP error: %s", resp.Status)
 		}
 		return nil
@@ -82,25 +142,126 @@ func (s *SentryAPI) Get(url string) (*http.Response, error) {
 		retry.Attempts(3),              // 设置重试次数
 		retry.Delay(2*time.Second),     // 设置重试间隔
 		retry.MaxDelay(10*time.Second), // 设置最大重试间隔
+		retry.DelayType(func(n uint, err error, config *retry.Config) time.Duration {
+			// 429 的等待由 throttler 负责, 这里不再额外退避
+			if _, ok := err.(errRateLimited); ok {
+				return 0
+			}
+			return retry.CombineDelay(retry.BackOffDelay, retry.RandomDelay)(n, err, config)
+		}),
 		retry.OnRetry(func(n uint, err error) {
 			log.Printf("Attempt %d: %v\n", n, err)
 		}),
+		retry.RetryIf(func(err error) bool {
+			// 调用方取消或超时后不再重试
+			return ctx.Err() == nil
+		}),
+		retry.LastErrorOnly(true),
+		retry.Context(ctx),
 	)
 	if err != nil {
+		cancel()
 		return nil, err
 	}
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
 	return resp, nil
 }
 
-// Organizations 获取组织列表
-func (s *SentryAPI) Organizations() ([]Organization, error) {
-	resp, err := s.Get("organizations/")
+// cancelOnClose 关闭响应体时取消 Get 的超时 context, 响应体读取完之前 context 保持有效
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelOnClose) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// errRateLimited Sentry 返回 429 时的错误
+type errRateLimited struct {
+	status string
+}
+
+func (e errRateLimited) Error() string {
+	return fmt.Sprintf("HTTP error: %s", e.status)
+}
+
+// GetAll 跟随 Link 响应头中的 next 游标获取全部分页, 并将合并后的结果解码到 v (切片指针)
+func (s *SentryAPI) GetAll(ctx context.Context, url string, v interface{}) error {
+	var items []json.RawMessage
+	cursor := ""
+	for page := 1; ; page++ {
+		pageURL := url
+		if cursor != "" {
+			pageURL = withQueryParam(url, "cursor", cursor)
+		}
+		resp, err := s.Get(ctx, pageURL)
+		if err != nil {
+			return err
+		}
+		var pageItems []json.RawMessage
+		err = json.NewDecoder(resp.Body).Decode(&pageItems)
+		link := resp.Header.Get("Link")
+		resp.Body.Close()
+		if err != nil {
+			return err
+		}
+		items = append(items, pageItems...)
+
+		next, ok := nextCursor(link)
+		if !ok {
+			break
+		}
+		if s.MaxPages > 0 && page >= s.MaxPages {
+			log.Printf("pagination: %s reached max pages limit (%d), results truncated\n", url, s.MaxPages)
+			break
+		}
+		cursor = next
+	}
+	if items == nil {
+		items = []json.RawMessage{}
+	}
+	body, err := json.Marshal(items)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resp.Body.Close()
+	return json.Unmarshal(body, v)
+}
+
+// nextCursor 解析 Link 响应头, 返回 rel="next" 且 results="true" 的游标
+// 例如: <https://sentry.io/api/0/...&cursor=0:100:0>; rel="next"; results="true"; cursor="0:100:0"
+func nextCursor(link string) (string, bool) {
+	for _, part := range strings.Split(link, ",") {
+		params := make(map[string]string)
+		for _, attr := range strings.Split(part, ";") {
+			kv := strings.SplitN(strings.TrimSpace(attr), "=", 2)
+			if len(kv) != 2 {
+				continue
+			}
+			params[kv[0]] = strings.Trim(kv[1], `"`)
+		}
+		if params["rel"] == "next" && params["results"] == "true" && params["cursor"] != "" {
+			return params["cursor"], true
+		}
+	}
+	return "", false
+}
+
+// withQueryParam 为相对 URL 追加查询参数
+func withQueryParam(rawURL, key, value string) string {
+	sep := "?"
+	if strings.Contains(rawURL, "?") {
+		sep = "&"
+	}
+	return rawURL + sep + key + "=" + url.QueryEscape(value)
+}
+
+// Organizations 获取组织列表
+func (s *SentryAPI) Organizations(ctx context.Context) ([]Organization, error) {
 	var orgs []Organization
-	err = json.NewDecoder(resp.Body).Decode(&orgs)
+	err := s.GetAll(ctx, "organizations/", &orgs)
 	if err != nil {
 		return nil, err
 	}
@@ -108,8 +269,8 @@ func (s *SentryAPI) Organizations() ([]Organization, error) {
 }
 
 // GetOrg 获取单个组织
-func (s *SentryAPI) GetOrg(orgSlug string) (*Organization, error) {
-	resp, err := s.Get("organizations/" + orgSlug + "/")
+func (s *SentryAPI) GetOrg(ctx context.Context, orgSlug string) (*Organization, error) {
+	resp, err := s.Get(ctx, "organizations/"+orgSlug+"/")
 	if err != nil {
 		return nil, fmt.Errorf("failed to get organization: %v", err)
 	}
@@ -125,34 +286,32 @@ func (s *SentryAPI) GetOrg(orgSlug string) (*Organization, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to decode organization JSON: %v", err)
 	}
-	// 打印解码后的组织信息
-	fmt.Println("Organization ID:", org.ID)
-	fmt.Println("Organization Slug:", org.Slug)
-	fmt.Println("Organization Name:", org.Name)
-	fmt.Println("Organization Status ID:", org.Status.ID)
-	fmt.Println("Organization Status Name:", org.Status.Name)
-
 	return &org, nil
 }
 
 // Projects 获取组织下的项目列表
-func (s *SentryAPI) Projects(orgSlug string) ([]Project, error) {
-	resp, err := s.Get(fmt.Sprintf("organizations/%s/projects/?all_projects=1", orgSlug))
+func (s *SentryAPI) Projects(ctx context.Context, orgSlug string) ([]Project, error) {
+	var projects []Project
+	err := s.GetAll(ctx, fmt.Sprintf("organizations/%s/projects/?all_projects=1", orgSlug), &projects)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-	var projects []Project
-	err = json.NewDecoder(resp.Body).Decode(&projects)
+	return projects, nil
+}
+
+// Teams 获取组织下的团队列表 (包含团队拥有的项目)
+func (s *SentryAPI) Teams(ctx context.Context, orgSlug string) ([]Team, error) {
+	var teams []Team
+	err := s.GetAll(ctx, fmt.Sprintf("organizations/%s/teams/", orgSlug), &teams)
 	if err != nil {
 		return nil, err
 	}
-	return projects, nil
+	return teams, nil
 }
 
 // GetProject 获取单个项目
-func (s *SentryAPI) GetProject(orgSlug, projectSlug string) (*Project, error) {
-	resp, err := s.Get(fmt.Sprintf("projects/%s/%s/", orgSlug, projectSlug))
+func (s *SentryAPI) GetProject(ctx context.Context, orgSlug, projectSlug string) (*Project, error) {
+	resp, err := s.Get(ctx, fmt.Sprintf("projects/%s/%s/", orgSlug, projectSlug))
 	if err != nil {
 		return nil, err
 	}
@@ -172,31 +331,30 @@ func (s *SentryAPI) GetProject(orgSlug, projectSlug string) (*Project, error) {
 }
 
 // ProjectStats 获取项目统计信息
-func (s *SentryAPI) ProjectStats(orgSlug, projectSlug string) (map[string]int, error) {
+func (s *SentryAPI) ProjectStats(ctx context.Context, orgSlug, projectSlug string) (Stats, error) {
 	firstDayMonth := time.Now().AddDate(0, 0, -time.Now().Day()+1).Unix()
 	today := time.Now().Unix()
 	statNames := []string{"received", "rejected", "blacklisted"}
-	stats := make(map[string][]Event)
-	projectEvents := make(map[string]int)
+	stats := make(map[string][]StatPoint)
+	projectEvents := make(Stats)
 
 	for _, statName := range statNames {
-		resp, err := s.Get(fmt.Sprintf("projects/%s/%s/stats/?stat=%s&since=%d&until=%d", orgSlug, projectSlug, statName, firstDayMonth, today))
+		resp, err := s.Get(ctx, fmt.Sprintf("projects/%s/%s/stats/?stat=%s&since=%d&until=%d", orgSlug, projectSlug, statName, firstDayMonth, today))
 		if err != nil {
 			return nil, err
 		}
 		defer resp.Body.Close()
-		var events []Event
-		body, err := ioutil.ReadAll(resp.Body)
-		err = json.Unmarshal(body, &events)
+		var points []StatPoint
+		err = json.NewDecoder(resp.Body).Decode(&points)
 		if err != nil {
 			return nil, err
 		}
-		stats[statName] = events
+		stats[statName] = points
 	}
-	for statName, events := range stats {
-		eventsCount := 0
-		for _, event := range events {
-			eventsCount += int(event[1].(float64))
+	for statName, points := range stats {
+		var eventsCount int64
+		for _, point := range points {
+			eventsCount += int64(point.Count)
 		}
 		projectEvents[statName] = eventsCount
 	}
@@ -205,8 +363,8 @@ func (s *SentryAPI) ProjectStats(orgSlug, projectSlug string) (map[string]int, e
 }
 
 // Environments 获取项目环境列表
-func (s *SentryAPI) Environments(orgSlug string, project Project) ([]string, error) {
-	resp, err := s.Get(fmt.Sprintf("projects/%s/%s/environments/", orgSlug, project.Slug))
+func (s *SentryAPI) Environments(ctx context.Context, orgSlug string, project Project) ([]string, error) {
+	resp, err := s.Get(ctx, fmt.Sprintf("projects/%s/%s/environments/", orgSlug, project.Slug))
 	if err != nil {
 		return nil, err
 	}
@@ -225,99 +383,94 @@ func (s *SentryAPI) Environments(orgSlug string, project Project) ([]string, err
 	return envs, nil
 }
 
-// Issues 获取项目问题列表
-func (s *SentryAPI) Issues(orgSlug string, project Project, environment string, age string) (map[string]interface{}, error) {
-	issuesURL := fmt.Sprintf("projects/%s/%s/issues/?project=%s&sort=date&query=age%%3A-%s", orgSlug, project.Slug, project.ID, age)
+// issuesQuery 返回问题搜索条件: 首次出现在 age 之内, lastSeenAfter 非零时只保留此后出现过事件的问题
+func issuesQuery(age string, lastSeenAfter time.Time) string {
+	query := "age:-" + age
+	if !lastSeenAfter.IsZero() {
+		query += " lastSeen:>" + lastSeenAfter.UTC().Format("2006-01-02T15:04:05")
+	}
+	return query
+}
+
+// Issues 获取项目问题列表, lastSeenAfter 非零时只返回此后出现过事件的问题, 用于增量同步
+func (s *SentryAPI) Issues(ctx context.Context, orgSlug string, project Project, environment string, age string, lastSeenAfter time.Time) ([]Issue, error) {
+	issuesURL := fmt.Sprintf("projects/%s/%s/issues/?project=%s&sort=date", orgSlug, project.Slug, project.ID)
+	issuesURL = withQueryParam(issuesURL, "query", issuesQuery(age, lastSeenAfter))
 
 	if environment != "" {
-		issuesURL = fmt.Sprintf("%s&environment=%s", issuesURL, environment)
+		issuesURL = withQueryParam(issuesURL, "environment", environment)
 	}
 
-	resp, err := s.Get(issuesURL)
+	issues := []Issue{}
+	err := s.GetAll(ctx, issuesURL, &issues)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
+	return issues, nil
+}
 
-	var issues []interface{}
-	err = json.NewDecoder(resp.Body).Decode(&issues)
-	if err != nil {
-		return nil, err
+// OrgIssues 通过组织级接口获取组织下所有项目 (projectIDs 为空时使用 project=-1) 在指定环境的问题列表
+// 返回的问题可通过 Issue.Project 区分所属项目, lastSeenAfter 与 Issues 相同
+func (s *SentryAPI) OrgIssues(ctx context.Context, orgSlug string, projectIDs []string, environment string, age string, lastSeenAfter time.Time) ([]Issue, error) {
+	issuesURL := fmt.Sprintf("organizations/%s/issues/?sort=date", orgSlug)
+	issuesURL = withQueryParam(issuesURL, "query", issuesQuery(age, lastSeenAfter))
+	if len(projectIDs) == 0 {
+		issuesURL = withQueryParam(issuesURL, "project", "-1")
 	}
-
-	// 明确处理空issues情况
-	if len(issues) == 0 {
-		return map[string]interface{}{"message": "No issues found", "all": []interface{}{}}, nil
+	for _, id := range projectIDs {
+		issuesURL = withQueryParam(issuesURL, "project", id)
 	}
-
-	result := map[string]interface{}{"all": issues}
 	if environment != "" {
-		result[environment] = issues
+		issuesURL = withQueryParam(issuesURL, "environment", environment)
+	}
+
+	issues := []Issue{}
+	err := s.GetAll(ctx, issuesURL, &issues)
+	if err != nil {
+		return nil, err
 	}
-	return result, nil
+	return issues, nil
 }
 
 // Events 获取项目事件列表
-func (s *SentryAPI) Events(orgSlug string, project Project, environment string) (map[string]interface{}, error) {
+func (s *SentryAPI) Events(ctx context.Context, orgSlug string, project Project, environment string) ([]Event, error) {
 	eventsURL := fmt.Sprintf("projects/%s/%s/events/?project=%s&sort=date", orgSlug, project.Slug, project.ID)
 
 	if environment != "" {
-		eventsURL = fmt.Sprintf("%s&environment=%s", eventsURL, environment)
+		eventsURL = withQueryParam(eventsURL, "environment", environment)
 	}
-	resp, err := s.Get(eventsURL)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	var events []interface{}
-	err = json.NewDecoder(resp.Body).Decode(&events)
+	events := []Event{}
+	err := s.GetAll(ctx, eventsURL, &events)
 	if err != nil {
 		return nil, err
 	}
-
-	result := map[string]interface{}{"all": events}
-	if environment != "" {
-		result[environment] = events
-	}
-	return result, nil
+	return events, nil
 }
 
 // IssueEvents 获取问题事件列表
-func (s *SentryAPI) IssueEvents(issueID string, environment string) (map[string]interface{}, error) {
+func (s *SentryAPI) IssueEvents(ctx context.Context, issueID string, environment string) ([]Event, error) {
 	issueEventsURL := fmt.Sprintf("issues/%s/events/", issueID)
 
 	if environment != "" {
-		issueEventsURL = fmt.Sprintf("%s&environment=%s&sort=date", issueEventsURL, environment)
+		issueEventsURL = withQueryParam(issueEventsURL+"?sort=date", "environment", environment)
 	}
-	resp, err := s.Get(issueEventsURL)
+	issueEvents := []Event{}
+	err := s.GetAll(ctx, issueEventsURL, &issueEvents)
 	if err != nil {
 		return nil, err
 	}
-	defer resp.Body.Close()
-
-	var issueEvents []interface{}
-	err = json.NewDecoder(resp.Body).Decode(&issueEvents)
-	if err != nil {
-		return nil, err
-	}
-
-	result := map[string]interface{}{"all": issueEvents}
-	if environment != "" {
-		result[environment] = issueEvents
-	}
-	return result, nil
+	return issueEvents, nil
 }
 
 // IssueRelease 获取问题发布版本
-func (s *SentryAPI) IssueRelease(issueID string, environment string) (string, error) {
+func (s *SentryAPI) IssueRelease(ctx context.Context, issueID string, environment string) (string, error) {
 	issueReleaseURL := fmt.Sprintf("issues/%s/current-release/", issueID)
 
 	if environment != "" {
-		issueReleaseURL = fmt.Sprintf("%s?environment=%s", issueReleaseURL, environment)
+		issueReleaseURL = withQueryParam(issueReleaseURL, "environment", environment)
 	}
 
-	resp, err := s.Get(issueReleaseURL)
+	resp, err := s.Get(ctx, issueReleaseURL)
 	if err != nil {
 		return "", err
 	}
@@ -337,59 +490,77 @@ func (s *SentryAPI) IssueRelease(issueID string, environment string) (string, er
 	return result.CurrentRelease.Release.Version, nil
 }
 
-func (s *SentryAPI) ProjectReleases(orgSlug string, project Project, environment string) (map[string]interface{}, error) {
+// ProjectReleases 获取项目发布版本列表
+func (s *SentryAPI) ProjectReleases(ctx context.Context, orgSlug string, project Project, environment string) ([]Release, error) {
 	projReleasesURL := fmt.Sprintf("organizations/%s/releases/?project=%s&sort=date", orgSlug, project.ID)
 
 	if environment != "" {
-		projReleasesURL = fmt.Sprintf("%s&environment=%s", projReleasesURL, environment)
+		projReleasesURL = withQueryParam(projReleasesURL, "environment", environment)
 	}
 
-	resp, err := s.Get(projReleasesURL)
+	releases := []Release{}
+	err := s.GetAll(ctx, projReleasesURL, &releases)
 	if err != nil {
 		return nil, fmt.Errorf("failed to fetch project releases: %v", err)
 	}
+	return releases, nil
+}
+
+// ProjectReleasesHealth 获取项目最近 limit 个发布版本及其健康数据 (adoption、crash-free)
+// summaryStatsPeriod 为健康数据的统计窗口, 如 24h
+func (s *SentryAPI) ProjectReleasesHealth(ctx context.Context, orgSlug string, project Project, environment string, limit int, summaryStatsPeriod string) ([]Release, error) {
+	releasesURL := fmt.Sprintf("organizations/%s/releases/?project=%s&sort=date&health=1&per_page=%d&summaryStatsPeriod=%s", orgSlug, project.ID, limit, summaryStatsPeriod)
+	if environment != "" {
+		releasesURL = withQueryParam(releasesURL, "environment", environment)
+	}
+
+	resp, err := s.Get(ctx, releasesURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch project releases health: %v", err)
+	}
 	defer resp.Body.Close()
 
-	var releases []interface{}
+	releases := []Release{}
 	err = json.NewDecoder(resp.Body).Decode(&releases)
 	if err != nil {
-		return nil, fmt.Errorf("failed to decode project releases JSON: %v", err)
+		return nil, fmt.Errorf("failed to decode project releases health JSON: %v", err)
 	}
+	return releases, nil
+}
 
-	releasesMap := make(map[string]interface{})
-	if environment != "" {
-		releasesMap[environment] = releases
-	} else {
-		releasesMap["all"] = releases
+// ReleaseDeploys 获取发布版本的部署记录
+func (s *SentryAPI) ReleaseDeploys(ctx context.Context, orgSlug string, version string) ([]Deploy, error) {
+	deploys := []Deploy{}
+	err := s.GetAll(ctx, fmt.Sprintf("organizations/%s/releases/%s/deploys/", orgSlug, url.PathEscape(version)), &deploys)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch release deploys: %v", err)
 	}
-
-	return releasesMap, nil
+	return deploys, nil
 }
 
 // rateLimit 获取项目速率限制
-func (s *SentryAPI) RateLimit(orgSlug, projectSlug string) (float64, error) {
+func (s *SentryAPI) RateLimit(ctx context.Context, orgSlug, projectSlug string) (float64, error) {
 	rateLimitURL := fmt.Sprintf("projects/%s/%s/keys/", orgSlug, projectSlug)
 
-	resp, err := s.Get(rateLimitURL)
+	resp, err := s.Get(ctx, rateLimitURL)
 	if err != nil {
 		return 0, fmt.Errorf("failed to fetch rate limit: %v", err)
 	}
 	defer resp.Body.Close()
 
-	var rateLimits []map[string]interface{}
-	err = json.NewDecoder(resp.Body).Decode(&rateLimits)
+	var keys []ProjectKey
+	err = json.NewDecoder(resp.Body).Decode(&keys)
 	if err != nil {
 		return 0, fmt.Errorf("failed to decode rate limit JSON: %v", err)
 	}
 
-	if len(rateLimits) > 0 && rateLimits[0]["rateLimit"] != nil {
-		rateLimit := rateLimits[0]["rateLimit"].(map[string]interface{})
-		window := rateLimit["window"].(float64)
-		count := rateLimit["count"].(float64)
-		if window != 0 {
-			rateLimitSecond := count / window
-			return rateLimitSecond, nil
+	// 使用第一个设置了限流的密钥, 没有设置限流的密钥 (rateLimit/window/count 为 null) 跳过
+	for _, key := range keys {
+		limit := key.RateLimit
+		if limit == nil || limit.Window == nil || limit.Count == nil || *limit.Window == 0 {
+			continue
 		}
+		return *limit.Count / *limit.Window, nil
 	}
 
 	return 0, nil
@@ -402,10 +573,10 @@ func (s *SentryAPI) liveness() bool {
 	return true // TODO: 实现实际的健康检查逻辑
 }
 
-func (s *SentryAPI) readiness() error {
+func (s *SentryAPI) readiness(ctx context.Context) error {
 	// 检查 SentryAPI 实例是否准备好接收请求
 	api := NewSentryAPI(s.BaseURL, s.AuthToken)
-	_, err := api.ProjectReleases("example_org_slug", Project{ID: "example_project_id", Slug: "example_project_slug"}, "")
+	_, err := api.ProjectReleases(ctx, "example_org_slug", Project{ID: "example_project_id", Slug: "example_project_slug"}, "")
 	if err != nil {
 		return fmt.Errorf("SentryAPI 就绪检查失败: %v", err)
 	}
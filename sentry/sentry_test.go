@@ -0,0 +1,157 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+func TestNextCursor(t *testing.T) {
+	tests := []struct {
+		name   string
+		link   string
+		cursor string
+		ok     bool
+	}{
+		{
+			name: "next page with results",
+			link: `<https://sentry.io/api/0/projects/acme/web/issues/?&cursor=0:0:1>; rel="previous"; results="false"; cursor="0:0:1", ` +
+				`<https://sentry.io/api/0/projects/acme/web/issues/?&cursor=0:100:0>; rel="next"; results="true"; cursor="0:100:0"`,
+			cursor: "0:100:0",
+			ok:     true,
+		},
+		{
+			name: "last page",
+			link: `<https://sentry.io/api/0/projects/acme/web/issues/?&cursor=0:0:1>; rel="previous"; results="true"; cursor="0:0:1", ` +
+				`<https://sentry.io/api/0/projects/acme/web/issues/?&cursor=0:200:0>; rel="next"; results="false"; cursor="0:200:0"`,
+		},
+		{
+			name:   "cursor with timestamp",
+			link:   `<https://sentry.io/api/0/organizations/acme/issues/?cursor=1718000000000:0:0>; rel="next"; results="true"; cursor="1718000000000:0:0"`,
+			cursor: "1718000000000:0:0",
+			ok:     true,
+		},
+		{
+			name: "next without cursor",
+			link: `<https://sentry.io/api/0/organizations/acme/issues/>; rel="next"; results="true"`,
+		},
+		{
+			name: "empty header",
+		},
+		{
+			name: "malformed header",
+			link: `rel=next;;results`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cursor, ok := nextCursor(tt.link)
+			if cursor != tt.cursor || ok != tt.ok {
+				t.Errorf("nextCursor() = %q, %v, want %q, %v", cursor, ok, tt.cursor, tt.ok)
+			}
+		})
+	}
+}
+
+func TestWithQueryParam(t *testing.T) {
+	tests := []struct {
+		url   string
+		key   string
+		value string
+		want  string
+	}{
+		{"organizations/", "cursor", "0:100:0", "organizations/?cursor=0%3A100%3A0"},
+		{"projects/acme/web/issues/?sort=date", "environment", "prod eu", "projects/acme/web/issues/?sort=date&environment=prod+eu"},
+		{"organizations/acme/issues/?project=-1", "query", "age:-1h is:unresolved", "organizations/acme/issues/?project=-1&query=age%3A-1h+is%3Aunresolved"},
+	}
+	for _, tt := range tests {
+		if got := withQueryParam(tt.url, tt.key, tt.value); got != tt.want {
+			t.Errorf("withQueryParam(%q, %q, %q) = %q, want %q", tt.url, tt.key, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestGetAll(t *testing.T) {
+	// pages cursor -> 该页的组织 slug 和下一页的游标
+	pages := map[string]struct {
+		slugs []string
+		next  string
+	}{
+		"":      {[]string{"a", "b"}, "0:2:0"},
+		"0:2:0": {[]string{"c", "d"}, "0:4:0"},
+		"0:4:0": {[]string{"e"}, ""},
+	}
+	tests := []struct {
+		name     string
+		maxPages int
+		want     []string
+		requests int
+	}{
+		{name: "all pages", maxPages: 0, want: []string{"a", "b", "c", "d", "e"}, requests: 3},
+		{name: "max pages", maxPages: 2, want: []string{"a", "b", "c", "d"}, requests: 2},
+		{name: "single page", maxPages: 1, want: []string{"a", "b"}, requests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			requests := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				requests++
+				cursor := r.URL.Query().Get("cursor")
+				page, ok := pages[cursor]
+				if !ok || r.URL.Query().Get("query") != "is:unresolved" {
+					t.Errorf("unexpected request %s", r.URL)
+				}
+				if page.next != "" {
+					w.Header().Set("Link", fmt.Sprintf(`<%s%s?cursor=%s>; rel="next"; results="true"; cursor="%s"`, r.Host, r.URL.Path, page.next, page.next))
+				} else {
+					w.Header().Set("Link", fmt.Sprintf(`<%s%s?cursor=0:9:0>; rel="next"; results="false"; cursor="0:9:0"`, r.Host, r.URL.Path))
+				}
+				orgs := []Organization{}
+				for _, slug := range page.slugs {
+					orgs = append(orgs, Organization{Slug: slug})
+				}
+				json.NewEncoder(w).Encode(orgs)
+			}))
+			defer server.Close()
+
+			api := NewSentryAPI(server.URL+"/api/0/", "token")
+			api.MaxPages = tt.maxPages
+			var orgs []Organization
+			if err := api.GetAll(context.Background(), "organizations/?query=is%3Aunresolved", &orgs); err != nil {
+				t.Fatalf("GetAll() = %v", err)
+			}
+			var got []string
+			for _, org := range orgs {
+				got = append(got, org.Slug)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("GetAll() = %v, want %v", got, tt.want)
+			}
+			if requests != tt.requests {
+				t.Errorf("GetAll() sent %d requests, want %d", requests, tt.requests)
+			}
+		})
+	}
+}
+
+func TestIssueReleaseEnvironment(t *testing.T) {
+	var environment, rawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		environment, rawQuery = r.URL.Query().Get("environment"), r.URL.RawQuery
+		w.Write([]byte(`{"currentRelease": {"release": {"version": "1.0"}}}`))
+	}))
+	defer server.Close()
+
+	api := NewSentryAPI(server.URL+"/api/0/", "token")
+	release, err := api.IssueRelease(context.Background(), "1", "prod & eu #1+")
+	if err != nil {
+		t.Fatalf("IssueRelease() = %v", err)
+	}
+	if release != "1.0" || environment != "prod & eu #1+" {
+		t.Errorf("IssueRelease() = %q with environment %q (query %q), want 1.0 with environment %q", release, environment, rawQuery, "prod & eu #1+")
+	}
+}
@@ -0,0 +1,60 @@
+package sentry
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// SessionGroupBy 会话统计分组的维度取值, 只有请求中 groupBy 的字段才会有值
+type SessionGroupBy struct {
+	Project       int64  `json:"project"`
+	Environment   string `json:"environment"`
+	Release       string `json:"release"`
+	SessionStatus string `json:"session.status"`
+}
+
+// SessionGroup 会话统计中的一个分组, Totals 的 key 为请求的 field, 如 sum(session)
+type SessionGroup struct {
+	By     SessionGroupBy     `json:"by"`
+	Totals map[string]float64 `json:"totals"`
+}
+
+// SessionsResult organizations/{org}/sessions/ 接口的返回结果
+type SessionsResult struct {
+	Start  time.Time      `json:"start"`
+	End    time.Time      `json:"end"`
+	Groups []SessionGroup `json:"groups"`
+}
+
+// Sessions 获取组织的会话 (release health) 统计
+// projectIDs 为空时查询全部项目, fields 如 sum(session)、count_unique(user), groupBy 如 project、environment、session.status
+func (s *SentryAPI) Sessions(ctx context.Context, orgSlug string, projectIDs []string, fields []string, groupBy []string, statsPeriod string, interval string) (*SessionsResult, error) {
+	sessionsURL := fmt.Sprintf("organizations/%s/sessions/?statsPeriod=%s&interval=%s", orgSlug, statsPeriod, interval)
+	if len(projectIDs) == 0 {
+		sessionsURL = withQueryParam(sessionsURL, "project", "-1")
+	}
+	for _, id := range projectIDs {
+		sessionsURL = withQueryParam(sessionsURL, "project", id)
+	}
+	for _, field := range fields {
+		sessionsURL = withQueryParam(sessionsURL, "field", field)
+	}
+	for _, group := range groupBy {
+		sessionsURL = withQueryParam(sessionsURL, "groupBy", group)
+	}
+
+	resp, err := s.Get(ctx, sessionsURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch sessions: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var result SessionsResult
+	err = json.NewDecoder(resp.Body).Decode(&result)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode sessions JSON: %v", err)
+	}
+	return &result, nil
+}
@@ -0,0 +1,215 @@
+package sentry
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultRateLimitedWait 429 响应既没有 Retry-After 也没有 Reset 时的等待时间
+	defaultRateLimitedWait = 2 * time.Second
+	// concurrentLimitWait 并发配额耗尽时的等待时间
+	concurrentLimitWait = 500 * time.Millisecond
+)
+
+// rateLimitState 单个 (组织, 接口) 维度的限流状态, 数值为 -1 表示 Sentry 未返回该响应头
+type rateLimitState struct {
+	remaining           int
+	concurrentRemaining int
+	// concurrentLimit 允许同时进行的请求数, 为 -1 表示 Sentry 没有返回并发限制;
+	// inFlight 为已发出但尚未收到响应的请求数, probed 表示已经收到过响应, 之前只允许一个请求试探并发限制
+	concurrentLimit int
+	inFlight        int
+	probed          bool
+	// released 有请求等待并发名额时创建, 归还名额时关闭以唤醒等待的请求
+	released chan struct{}
+	reset    time.Time
+	// blockedUntil 收到 429 后在此时间之前不再发送请求
+	blockedUntil time.Time
+	// next 按剩余配额均匀分布请求时, 下一个请求最早的发送时间
+	next time.Time
+}
+
+// throttler 读取 Sentry 的限流响应头, 按组织和接口对请求进行节流
+type throttler struct {
+	mu     sync.Mutex
+	states map[string]*rateLimitState
+}
+
+func newThrottler() *throttler {
+	return &throttler{states: make(map[string]*rateLimitState)}
+}
+
+func throttleKey(org, endpoint string) string {
+	return org + " " + endpoint
+}
+
+func (t *throttler) state(key string) *rateLimitState {
+	st, ok := t.states[key]
+	if !ok {
+		st = &rateLimitState{remaining: -1, concurrentRemaining: -1, concurrentLimit: -1}
+		t.states[key] = st
+	}
+	return st
+}
+
+// wait 阻塞直到允许向 key 对应的接口发送请求, ctx 取消时立即返回
+// reserve 为其他共享同一组织配额的工具预留的请求数
+func (t *throttler) wait(ctx context.Context, key string, reserve int) error {
+	if t == nil {
+		return nil
+	}
+	delay := t.reserveSlot(key, reserve, time.Now())
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// acquire 阻塞直到 key 对应接口正在进行的请求数低于 Sentry 的并发限制, 并占用一个并发名额,
+// 请求完成后需要调用 release; ctx 取消时立即返回错误
+func (t *throttler) acquire(ctx context.Context, key string) error {
+	if t == nil {
+		return nil
+	}
+	for {
+		released := t.tryAcquire(key)
+		if released == nil {
+			return nil
+		}
+		select {
+		case <-released:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// tryAcquire 并发名额未用完时占用一个名额并返回 nil, 否则返回下一次归还名额时关闭的 channel
+func (t *throttler) tryAcquire(key string) <-chan struct{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.state(key)
+	// 至少允许一个请求, 否则无法从响应头中得知配额已恢复
+	if st.inFlight > 0 && (!st.probed || st.concurrentLimit >= 0 && st.inFlight >= st.concurrentLimit) {
+		if st.released == nil {
+			st.released = make(chan struct{})
+		}
+		return st.released
+	}
+	st.inFlight++
+	return nil
+}
+
+// release 归还 acquire 占用的并发名额
+func (t *throttler) release(key string) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.state(key)
+	if st.inFlight > 0 {
+		st.inFlight--
+	}
+	if st.released != nil {
+		close(st.released)
+		st.released = nil
+	}
+}
+
+// reserveSlot 计算 key 下一个请求需要等待的时间, 并为其占用一个发送时隙
+func (t *throttler) reserveSlot(key string, reserve int, now time.Time) time.Duration {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.state(key)
+
+	start := now
+	if st.blockedUntil.After(start) {
+		start = st.blockedUntil
+	}
+	if st.reset.After(now) && st.remaining >= 0 {
+		available := st.remaining - reserve
+		if available <= 0 {
+			// 配额 (扣除预留) 已用完, 等到窗口重置
+			if st.reset.After(start) {
+				start = st.reset
+			}
+		} else {
+			// 在重置前把剩余配额均匀分布开, 避免一次性耗尽
+			if st.next.After(start) {
+				start = st.next
+			}
+			if st.reset.After(start) {
+				st.next = start.Add(st.reset.Sub(start) / time.Duration(available))
+			}
+			st.remaining--
+		}
+	}
+	if st.concurrentRemaining == 0 && !start.After(now) {
+		start = now.Add(concurrentLimitWait)
+	}
+	return start.Sub(now)
+}
+
+// update 根据响应头刷新 key 的限流状态
+func (t *throttler) update(key string, resp *http.Response) {
+	if t == nil || resp == nil {
+		return
+	}
+	now := time.Now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	st := t.state(key)
+	st.probed = true
+
+	if v, err := strconv.Atoi(resp.Header.Get("X-Sentry-Rate-Limit-Remaining")); err == nil {
+		st.remaining = v
+	}
+	if v, err := strconv.Atoi(resp.Header.Get("X-Sentry-Rate-Limit-ConcurrentRemaining")); err == nil {
+		st.concurrentRemaining = v
+		// 没有 ConcurrentLimit 响应头时, 用正在进行的请求数 (包括本次请求) 加上剩余名额估算并发限制
+		st.concurrentLimit = st.inFlight + v
+	}
+	if v, err := strconv.Atoi(resp.Header.Get("X-Sentry-Rate-Limit-ConcurrentLimit")); err == nil {
+		st.concurrentLimit = v
+	}
+	if v, err := strconv.ParseInt(resp.Header.Get("X-Sentry-Rate-Limit-Reset"), 10, 64); err == nil {
+		reset := time.Unix(v, 0)
+		if !reset.Equal(st.reset) {
+			// 新的限流窗口, 重新计算请求间隔
+			st.next = time.Time{}
+		}
+		st.reset = reset
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		st.blockedUntil = now.Add(retryAfter(resp, st.reset, now))
+	}
+}
+
+// retryAfter 计算 429 响应后需要等待的时间, 优先使用 Retry-After, 其次使用限流窗口的重置时间
+func retryAfter(resp *http.Response, reset time.Time, now time.Time) time.Duration {
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.ParseFloat(v, 64); err == nil {
+			return time.Duration(seconds * float64(time.Second))
+		}
+		if date, err := http.ParseTime(v); err == nil {
+			return date.Sub(now)
+		}
+	}
+	if reset.After(now) {
+		return reset.Sub(now)
+	}
+	return defaultRateLimitedWait
+}
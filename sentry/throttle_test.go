@@ -0,0 +1,203 @@
+package sentry
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestReserveSlot(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name    string
+		state   rateLimitState
+		reserve int
+		// want 连续调用 reserveSlot 得到的等待时间
+		want []time.Duration
+	}{
+		{
+			name:  "no rate limit headers",
+			state: rateLimitState{remaining: -1, concurrentRemaining: -1, concurrentLimit: -1},
+			want:  []time.Duration{0, 0, 0},
+		},
+		{
+			name:  "spread remaining quota until reset",
+			state: rateLimitState{remaining: 10, concurrentRemaining: -1, concurrentLimit: -1, reset: now.Add(10 * time.Second)},
+			want:  []time.Duration{0, time.Second, 2 * time.Second},
+		},
+		{
+			name:    "quota reserved for other tools",
+			state:   rateLimitState{remaining: 3, concurrentRemaining: -1, concurrentLimit: -1, reset: now.Add(6 * time.Second)},
+			reserve: 2,
+			want:    []time.Duration{0, 6 * time.Second},
+		},
+		{
+			name:  "quota exhausted",
+			state: rateLimitState{remaining: 0, concurrentRemaining: -1, concurrentLimit: -1, reset: now.Add(5 * time.Second)},
+			want:  []time.Duration{5 * time.Second, 5 * time.Second},
+		},
+		{
+			name:  "reset in the past",
+			state: rateLimitState{remaining: 0, concurrentRemaining: -1, concurrentLimit: -1, reset: now.Add(-time.Second)},
+			want:  []time.Duration{0},
+		},
+		{
+			name:  "blocked after 429",
+			state: rateLimitState{remaining: -1, concurrentRemaining: -1, concurrentLimit: -1, blockedUntil: now.Add(3 * time.Second)},
+			want:  []time.Duration{3 * time.Second, 3 * time.Second},
+		},
+		{
+			name:  "no concurrent quota",
+			state: rateLimitState{remaining: -1, concurrentRemaining: 0, concurrentLimit: 2},
+			want:  []time.Duration{concurrentLimitWait},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newThrottler()
+			st := tt.state
+			th.states["key"] = &st
+			for i, want := range tt.want {
+				if got := th.reserveSlot("key", tt.reserve, now); got != want {
+					t.Errorf("reserveSlot() call %d = %s, want %s", i+1, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestThrottlerUpdate(t *testing.T) {
+	reset := time.Now().Add(30 * time.Second).Truncate(time.Second)
+	tests := []struct {
+		name     string
+		inFlight int
+		status   int
+		headers  map[string]string
+		// want 只比较 remaining、concurrentRemaining、concurrentLimit 和 reset
+		want rateLimitState
+		// blocked 是否应当在一段时间内不再发送请求
+		blocked bool
+	}{
+		{
+			name:   "no headers",
+			status: http.StatusOK,
+			want:   rateLimitState{remaining: -1, concurrentRemaining: -1, concurrentLimit: -1},
+		},
+		{
+			name:   "all headers",
+			status: http.StatusOK,
+			headers: map[string]string{
+				"X-Sentry-Rate-Limit-Remaining":           "39",
+				"X-Sentry-Rate-Limit-ConcurrentRemaining": "4",
+				"X-Sentry-Rate-Limit-ConcurrentLimit":     "5",
+				"X-Sentry-Rate-Limit-Reset":               strconv.FormatInt(reset.Unix(), 10),
+			},
+			want: rateLimitState{remaining: 39, concurrentRemaining: 4, concurrentLimit: 5, reset: reset},
+		},
+		{
+			name:     "concurrent limit estimated from requests in flight",
+			inFlight: 3,
+			status:   http.StatusOK,
+			headers:  map[string]string{"X-Sentry-Rate-Limit-ConcurrentRemaining": "2"},
+			want:     rateLimitState{remaining: -1, concurrentRemaining: 2, concurrentLimit: 5},
+		},
+		{
+			name:    "invalid values ignored",
+			status:  http.StatusOK,
+			headers: map[string]string{"X-Sentry-Rate-Limit-Remaining": "many", "X-Sentry-Rate-Limit-Reset": "soon"},
+			want:    rateLimitState{remaining: -1, concurrentRemaining: -1, concurrentLimit: -1},
+		},
+		{
+			name:    "rate limited",
+			status:  http.StatusTooManyRequests,
+			headers: map[string]string{"X-Sentry-Rate-Limit-Remaining": "0", "Retry-After": "10"},
+			want:    rateLimitState{remaining: 0, concurrentRemaining: -1, concurrentLimit: -1},
+			blocked: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			th := newThrottler()
+			th.state("key").inFlight = tt.inFlight
+			resp := &http.Response{StatusCode: tt.status, Header: make(http.Header)}
+			for k, v := range tt.headers {
+				resp.Header.Set(k, v)
+			}
+			th.update("key", resp)
+
+			st := th.states["key"]
+			if !st.probed {
+				t.Errorf("probed = false, want true")
+			}
+			if st.remaining != tt.want.remaining || st.concurrentRemaining != tt.want.concurrentRemaining ||
+				st.concurrentLimit != tt.want.concurrentLimit || !st.reset.Equal(tt.want.reset) {
+				t.Errorf("state = remaining %d, concurrentRemaining %d, concurrentLimit %d, reset %s, want %d, %d, %d, %s",
+					st.remaining, st.concurrentRemaining, st.concurrentLimit, st.reset,
+					tt.want.remaining, tt.want.concurrentRemaining, tt.want.concurrentLimit, tt.want.reset)
+			}
+			if blocked := st.blockedUntil.After(time.Now()); blocked != tt.blocked {
+				t.Errorf("blocked = %v, want %v", blocked, tt.blocked)
+			}
+		})
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	tests := []struct {
+		name       string
+		retryAfter string
+		reset      time.Time
+		want       time.Duration
+	}{
+		{"seconds", "7", time.Time{}, 7 * time.Second},
+		{"fractional seconds", "1.5", time.Time{}, 1500 * time.Millisecond},
+		{"http date", now.Add(4 * time.Second).UTC().Format(http.TimeFormat), time.Time{}, 4 * time.Second},
+		{"reset header", "", now.Add(20 * time.Second), 20 * time.Second},
+		{"invalid retry-after falls back to reset", "later", now.Add(20 * time.Second), 20 * time.Second},
+		{"nothing", "", time.Time{}, defaultRateLimitedWait},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: http.StatusTooManyRequests, Header: make(http.Header)}
+			if tt.retryAfter != "" {
+				resp.Header.Set("Retry-After", tt.retryAfter)
+			}
+			if got := retryAfter(resp, tt.reset, now); got != tt.want {
+				t.Errorf("retryAfter() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTryAcquire(t *testing.T) {
+	th := newThrottler()
+	// 收到第一个响应之前只允许一个请求
+	if th.tryAcquire("key") != nil {
+		t.Fatalf("first request blocked")
+	}
+	released := th.tryAcquire("key")
+	if released == nil {
+		t.Fatalf("second request allowed before the concurrent limit is known")
+	}
+
+	resp := &http.Response{StatusCode: http.StatusOK, Header: make(http.Header)}
+	resp.Header.Set("X-Sentry-Rate-Limit-ConcurrentLimit", "2")
+	th.update("key", resp)
+	th.release("key")
+	select {
+	case <-released:
+	default:
+		t.Fatalf("waiting request not woken up by release")
+	}
+
+	for i := 0; i < 2; i++ {
+		if th.tryAcquire("key") != nil {
+			t.Fatalf("request %d blocked below the concurrent limit", i+1)
+		}
+	}
+	if th.tryAcquire("key") == nil {
+		t.Fatalf("request allowed above the concurrent limit")
+	}
+}
@@ -0,0 +1,179 @@
+package sentry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// Count Sentry 中的计数字段, 不同接口/版本可能返回字符串 ("12") 或数字 (12)
+type Count int64
+
+// UnmarshalJSON 兼容字符串和数字两种格式, null 和空字符串视为 0
+func (c *Count) UnmarshalJSON(b []byte) error {
+	b = bytes.Trim(b, `"`)
+	if len(b) == 0 || string(b) == "null" {
+		*c = 0
+		return nil
+	}
+	v, err := strconv.ParseFloat(string(b), 64)
+	if err != nil {
+		return fmt.Errorf("invalid count %q: %v", b, err)
+	}
+	*c = Count(v)
+	return nil
+}
+
+// IssueMetadata 问题的元数据
+type IssueMetadata struct {
+	Type     string `json:"type"`
+	Value    string `json:"value"`
+	Title    string `json:"title"`
+	Filename string `json:"filename"`
+	Function string `json:"function"`
+}
+
+// IssueProject 问题所属项目的摘要信息
+type IssueProject struct {
+	ID       string `json:"id"`
+	Slug     string `json:"slug"`
+	Name     string `json:"name"`
+	Platform string `json:"platform"`
+}
+
+// Issue Sentry 问题 (group)
+type Issue struct {
+	ID          string        `json:"id"`
+	ShortID     string        `json:"shortId"`
+	Title       string        `json:"title"`
+	Culprit     string        `json:"culprit"`
+	Logger      string        `json:"logger"`
+	Level       string        `json:"level"`
+	Status      string        `json:"status"`
+	Substatus   string        `json:"substatus"`
+	Priority    string        `json:"priority"`
+	Platform    string        `json:"platform"`
+	Count       Count         `json:"count"`
+	UserCount   Count         `json:"userCount"`
+	FirstSeen   time.Time     `json:"firstSeen"`
+	LastSeen    time.Time     `json:"lastSeen"`
+	IsUnhandled bool          `json:"isUnhandled"`
+	Metadata    IssueMetadata `json:"metadata"`
+	Project     IssueProject  `json:"project"`
+}
+
+// EventTag 事件标签
+type EventTag struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+// Event Sentry 事件
+type Event struct {
+	ID          string     `json:"id"`
+	EventID     string     `json:"eventID"`
+	GroupID     string     `json:"groupID"`
+	ProjectID   string     `json:"projectID"`
+	Title       string     `json:"title"`
+	Message     string     `json:"message"`
+	Platform    string     `json:"platform"`
+	EventType   string     `json:"event.type"`
+	DateCreated time.Time  `json:"dateCreated"`
+	Tags        []EventTag `json:"tags"`
+}
+
+// Tag 返回事件中指定标签的值, 不存在时返回空字符串
+func (e Event) Tag(key string) string {
+	for _, tag := range e.Tags {
+		if tag.Key == key {
+			return tag.Value
+		}
+	}
+	return ""
+}
+
+// Deploy 发布版本的部署记录
+type Deploy struct {
+	ID           string     `json:"id"`
+	Environment  string     `json:"environment"`
+	Name         string     `json:"name"`
+	DateStarted  *time.Time `json:"dateStarted"`
+	DateFinished *time.Time `json:"dateFinished"`
+}
+
+// ReleaseHealth 发布版本在某个项目中的健康数据 (请求时需带 health=1), 百分比取值范围 0-100
+type ReleaseHealth struct {
+	HasHealthData     bool     `json:"hasHealthData"`
+	Adoption          *float64 `json:"adoption"`
+	SessionsAdoption  *float64 `json:"sessionsAdoption"`
+	CrashFreeUsers    *float64 `json:"crashFreeUsers"`
+	CrashFreeSessions *float64 `json:"crashFreeSessions"`
+	TotalUsers        Count    `json:"totalUsers"`
+	TotalSessions     Count    `json:"totalSessions"`
+}
+
+// ReleaseProject 发布版本关联的项目
+type ReleaseProject struct {
+	ID         int64          `json:"id"`
+	Slug       string         `json:"slug"`
+	Name       string         `json:"name"`
+	Platform   string         `json:"platform"`
+	NewGroups  Count          `json:"newGroups"`
+	HealthData *ReleaseHealth `json:"healthData"`
+}
+
+// Release Sentry 发布版本
+type Release struct {
+	Version      string           `json:"version"`
+	ShortVersion string           `json:"shortVersion"`
+	Ref          string           `json:"ref"`
+	DateCreated  time.Time        `json:"dateCreated"`
+	DateReleased *time.Time       `json:"dateReleased"`
+	FirstEvent   *time.Time       `json:"firstEvent"`
+	LastEvent    *time.Time       `json:"lastEvent"`
+	NewGroups    Count            `json:"newGroups"`
+	LastDeploy   *Deploy          `json:"lastDeploy"`
+	Projects     []ReleaseProject `json:"projects"`
+}
+
+// StatPoint 旧版 stats 接口返回的数据点, 格式为 [timestamp, count]
+type StatPoint struct {
+	Timestamp int64
+	Count     Count
+}
+
+// UnmarshalJSON 解析 [timestamp, count] 数组
+func (p *StatPoint) UnmarshalJSON(b []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+	if len(raw) != 2 {
+		return fmt.Errorf("invalid stat point: %s", b)
+	}
+	var ts Count
+	if err := json.Unmarshal(raw[0], &ts); err != nil {
+		return err
+	}
+	p.Timestamp = int64(ts)
+	return json.Unmarshal(raw[1], &p.Count)
+}
+
+// Stats 项目按统计项 (received/rejected/blacklisted) 汇总的事件数
+type Stats map[string]int64
+
+// KeyRateLimit 客户端密钥 (DSN) 的限流配置, 未设置限流时字段为 null
+type KeyRateLimit struct {
+	Window *float64 `json:"window"`
+	Count  *float64 `json:"count"`
+}
+
+// ProjectKey 项目的客户端密钥 (DSN)
+type ProjectKey struct {
+	ID        string        `json:"id"`
+	Name      string        `json:"name"`
+	IsActive  bool          `json:"isActive"`
+	RateLimit *KeyRateLimit `json:"rateLimit"`
+}